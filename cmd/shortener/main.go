@@ -1,12 +1,15 @@
 package main
 
 import (
-	"net/http"
+	"context"
+	"flag"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/learies/go-url-shortener/config"
+	"github.com/learies/go-url-shortener/internal/app"
 	"github.com/learies/go-url-shortener/internal/logger"
-	"github.com/learies/go-url-shortener/internal/router"
 )
 
 func main() {
@@ -18,12 +21,25 @@ func main() {
 		return
 	}
 
-	r := router.NewRouter(cfg)
+	if args := flag.Args(); len(args) > 0 && args[0] == "migrate" {
+		if err := runMigrate(cfg, args[1:]); err != nil {
+			logger.Log.Error("Error running migrations", "err", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	logger.Log.Info("Starting server", "address", cfg.Address)
-	err = http.ListenAndServe(cfg.Address, r)
+	service, err := app.New(cfg)
 	if err != nil {
-		logger.Log.Error("Error starting server", "err", err)
+		logger.Log.Error("Error initializing service", "err", err)
+		os.Exit(1)
+	}
+
+	if err := service.Run(ctx); err != nil {
+		logger.Log.Error("Error running server", "err", err)
 		os.Exit(1)
 	}
 }
@@ -6,15 +6,20 @@ import (
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/learies/go-url-shortener/config"
+	"github.com/learies/go-url-shortener/internal/app"
+	"github.com/learies/go-url-shortener/internal/auth"
+	"github.com/learies/go-url-shortener/internal/auth/oidctest"
 	"github.com/learies/go-url-shortener/internal/logger"
 	"github.com/learies/go-url-shortener/internal/models"
-	"github.com/learies/go-url-shortener/internal/router"
 )
 
 func TestMainHandler(t *testing.T) {
@@ -28,8 +33,25 @@ func TestMainHandler(t *testing.T) {
 	}
 
 	cfg.BaseURL = "http://localhost:8080"
-
-	r := router.NewRouter(cfg)
+	cfg.DevMode = true
+	cfg.ClickBatchSize = 1
+	cfg.DeleteBatchSize = 1
+	cfg.RateLimitBackend = "none"
+	cfg.MaxLinksPerUser = 5
+	cfg.MaxBatchSize = 10
+	cfg.MaxRequestBytes = 4096
+
+	idp := oidctest.NewServer("shortener")
+	defer idp.Close()
+	cfg.OIDCIssuer = idp.Issuer()
+	cfg.OIDCClientID = "shortener"
+
+	service, err := app.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { service.Close() })
+	r := service.Handler()
 
 	t.Run("POST valid URL", func(t *testing.T) {
 		req, err := http.NewRequest(http.MethodPost, "/", strings.NewReader("http://example.com"))
@@ -94,7 +116,7 @@ func TestMainHandler(t *testing.T) {
 	})
 
 	t.Run("POST /api/shorten valid URL", func(t *testing.T) {
-		requestBody, _ := json.Marshal(models.Request{URL: "http://example.com"})
+		requestBody, _ := json.Marshal(models.Request{URL: "http://example.com/api"})
 		req, err := http.NewRequest(http.MethodPost, "/api/shorten", bytes.NewReader(requestBody))
 		assert.NoError(t, err)
 
@@ -105,6 +127,467 @@ func TestMainHandler(t *testing.T) {
 		assert.Contains(t, rec.Header().Get("Content-Type"), "application/json")
 	})
 
+	t.Run("duplicate original URL", func(t *testing.T) {
+		do := func(method, target, body string) *httptest.ResponseRecorder {
+			req, err := http.NewRequest(method, target, strings.NewReader(body))
+			assert.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			return rec
+		}
+
+		rec := do(http.MethodPost, "/", "http://example.com/duplicate")
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		stored := rec.Body.String()
+
+		rec = do(http.MethodPost, "/", "http://example.com/duplicate")
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Equal(t, stored, rec.Body.String())
+
+		rec = do(http.MethodPost, "/api/shorten", `{"url":"http://example.com/duplicate"}`)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		var response models.Response
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, stored, response.Result)
+
+		rec = do(http.MethodPost, "/api/shorten/batch", `[
+			{"correlation_id":"1","original_url":"http://example.com/duplicate"},
+			{"correlation_id":"2","original_url":"http://example.com/duplicate-new"},
+			{"correlation_id":"3","original_url":"http://example.com/duplicate-new"}
+		]`)
+		var batch []models.BatchURLResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &batch))
+		if assert.Len(t, batch, 3) {
+			assert.Equal(t, models.BatchStatusExists, batch[0].Status)
+			assert.Equal(t, stored, batch[0].ShortURL)
+			assert.Equal(t, models.BatchStatusCreated, batch[1].Status)
+			assert.Equal(t, models.BatchStatusExists, batch[2].Status)
+			assert.Equal(t, batch[1].ShortURL, batch[2].ShortURL)
+		}
+	})
+
+	t.Run("POST /api/shorten with alias", func(t *testing.T) {
+		requestBody, _ := json.Marshal(models.Request{URL: "http://example.com/spring", Alias: "spring-sale"})
+		req, err := http.NewRequest(http.MethodPost, "/api/shorten", bytes.NewReader(requestBody))
+		assert.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Contains(t, rec.Body.String(), cfg.BaseURL+"/spring-sale")
+
+		req, err = http.NewRequest(http.MethodGet, "/spring-sale", nil)
+		assert.NoError(t, err)
+
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusTemporaryRedirect, rec.Code)
+		assert.Equal(t, "http://example.com/spring", rec.Header().Get("Location"))
+	})
+
+	t.Run("POST /api/shorten taken alias", func(t *testing.T) {
+		requestBody, _ := json.Marshal(models.Request{URL: "http://example.com/other", Alias: "spring-sale"})
+		req, err := http.NewRequest(http.MethodPost, "/api/shorten", bytes.NewReader(requestBody))
+		assert.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+
+	t.Run("POST /api/shorten invalid alias", func(t *testing.T) {
+		for _, alias := range []string{"api", "ping", "a", "bad/alias"} {
+			requestBody, _ := json.Marshal(models.Request{URL: "http://example.com", Alias: alias})
+			req, err := http.NewRequest(http.MethodPost, "/api/shorten", bytes.NewReader(requestBody))
+			assert.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code, alias)
+		}
+	})
+
+	t.Run("GET expired short URL", func(t *testing.T) {
+		requestBody, _ := json.Marshal(models.Request{URL: "http://example.com/expiring", TTLSeconds: 1})
+		req, err := http.NewRequest(http.MethodPost, "/api/shorten", bytes.NewReader(requestBody))
+		assert.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		var response models.Response
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		shortURL := strings.TrimPrefix(response.Result, cfg.BaseURL)
+
+		req, err = http.NewRequest(http.MethodGet, shortURL, nil)
+		assert.NoError(t, err)
+
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusTemporaryRedirect, rec.Code)
+
+		time.Sleep(time.Second)
+
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusGone, rec.Code)
+
+		// Истёкшая ссылка не мешает сократить URL заново: создаётся новая ссылка с новым сроком
+		requestBody, _ = json.Marshal(models.Request{URL: "http://example.com/expiring", TTLSeconds: 60})
+		req, err = http.NewRequest(http.MethodPost, "/api/shorten", bytes.NewReader(requestBody))
+		assert.NoError(t, err)
+
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		var renewed models.Response
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &renewed))
+		assert.NotEqual(t, response.Result, renewed.Result)
+
+		req, err = http.NewRequest(http.MethodGet, strings.TrimPrefix(renewed.Result, cfg.BaseURL), nil)
+		assert.NoError(t, err)
+
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusTemporaryRedirect, rec.Code)
+	})
+
+	t.Run("POST /api/shorten invalid expiry", func(t *testing.T) {
+		past := time.Now().Add(-time.Hour)
+		for _, request := range []models.Request{
+			{URL: "http://example.com", ExpiresAt: &past},
+			{URL: "http://example.com", TTLSeconds: -1},
+			{URL: "http://example.com", TTLSeconds: 60, ExpiresAt: &past},
+		} {
+			requestBody, _ := json.Marshal(request)
+			req, err := http.NewRequest(http.MethodPost, "/api/shorten", bytes.NewReader(requestBody))
+			assert.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		}
+	})
+
+	t.Run("GET /api/user/urls/{short}/stats", func(t *testing.T) {
+		requestBody, _ := json.Marshal(models.Request{URL: "http://example.com/stats"})
+		req, err := http.NewRequest(http.MethodPost, "/api/shorten", bytes.NewReader(requestBody))
+		assert.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		cookies := rec.Result().Cookies()
+		assert.NotEmpty(t, cookies)
+
+		var response models.Response
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		shortURL := strings.TrimPrefix(response.Result, cfg.BaseURL+"/")
+
+		for _, remoteAddr := range []string{"192.0.2.1:1234", "192.0.2.2:1234", "198.51.100.1:1234"} {
+			req, err = http.NewRequest(http.MethodGet, "/"+shortURL, nil)
+			assert.NoError(t, err)
+			req.RemoteAddr = remoteAddr
+
+			rec = httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusTemporaryRedirect, rec.Code)
+		}
+
+		getStats := func(withCookies bool) (*httptest.ResponseRecorder, models.ClickStats) {
+			req, err := http.NewRequest(http.MethodGet, "/api/user/urls/"+shortURL+"/stats", nil)
+			assert.NoError(t, err)
+			if withCookies {
+				for _, cookie := range cookies {
+					req.AddCookie(cookie)
+				}
+			}
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			var stats models.ClickStats
+			if rec.Code == http.StatusOK {
+				assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+			}
+			return rec, stats
+		}
+
+		assert.Eventually(t, func() bool {
+			_, stats := getStats(true)
+			return stats.TotalClicks == 3
+		}, time.Second, 10*time.Millisecond)
+
+		rec, stats := getStats(true)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, int64(2), stats.UniqueVisitors)
+		assert.Len(t, stats.Daily, 1)
+
+		rec, _ = getStats(false)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("DELETE /api/user/urls", func(t *testing.T) {
+		requestBody, _ := json.Marshal(models.Request{URL: "http://example.com/delete"})
+		req, err := http.NewRequest(http.MethodPost, "/api/shorten", bytes.NewReader(requestBody))
+		assert.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		cookies := rec.Result().Cookies()
+
+		var response models.Response
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		shortURL := strings.TrimPrefix(response.Result, cfg.BaseURL+"/")
+
+		req, err = http.NewRequest(http.MethodDelete, "/api/user/urls", strings.NewReader(`["`+shortURL+`"]`))
+		assert.NoError(t, err)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+
+		// Удаление выполняется в фоне
+		assert.Eventually(t, func() bool {
+			req, err := http.NewRequest(http.MethodGet, "/"+shortURL, nil)
+			assert.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			return rec.Code == http.StatusGone
+		}, 3*time.Second, 50*time.Millisecond)
+	})
+
+	t.Run("API keys", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/api/user/keys", strings.NewReader(`{"name":"ci"}`))
+		assert.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		cookies := rec.Result().Cookies()
+
+		var created models.APIKeyResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+		assert.Equal(t, "ci", created.Name)
+		assert.True(t, strings.HasPrefix(created.Key, created.Prefix))
+
+		// Ссылка, созданная по ключу, принадлежит владельцу ключа
+		req, err = http.NewRequest(http.MethodPost, "/api/shorten/batch",
+			strings.NewReader(`[{"correlation_id":"1","original_url":"http://example.com/apikey"}]`))
+		assert.NoError(t, err)
+		req.Header.Set("X-API-Key", created.Key)
+
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Empty(t, rec.Result().Cookies())
+
+		req, err = http.NewRequest(http.MethodGet, "/api/user/urls", nil)
+		assert.NoError(t, err)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "http://example.com/apikey")
+
+		req, err = http.NewRequest(http.MethodGet, "/api/user/keys", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+created.Key)
+
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NotContains(t, rec.Body.String(), created.Key)
+		assert.Contains(t, rec.Body.String(), created.ID)
+
+		req, err = http.NewRequest(http.MethodDelete, "/api/user/keys/"+created.ID, nil)
+		assert.NoError(t, err)
+		req.Header.Set("X-API-Key", created.Key)
+
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		req, err = http.NewRequest(http.MethodGet, "/api/user/urls", nil)
+		assert.NoError(t, err)
+		req.Header.Set("X-API-Key", created.Key)
+
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("register, claim links and log in", func(t *testing.T) {
+		do := func(method, target, body string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+			req, err := http.NewRequest(method, target, strings.NewReader(body))
+			assert.NoError(t, err)
+			for _, cookie := range cookies {
+				req.AddCookie(cookie)
+			}
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			return rec
+		}
+
+		rec := do(http.MethodPost, "/", "http://example.com/claimed", nil)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		anonymous := rec.Result().Cookies()
+
+		// Ключ доступа анонимного пользователя переходит в аккаунт вместе со ссылками
+		rec = do(http.MethodPost, "/api/user/keys", `{"name":"claimed"}`, anonymous)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		var key models.APIKeyResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &key))
+
+		credentials := `{"login":"Alice","password":"correct horse","claim_links":true}`
+		rec = do(http.MethodPost, "/api/user/register", credentials, anonymous)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		var user models.UserResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &user))
+		assert.Equal(t, "alice", user.Login)
+		assert.Equal(t, int64(1), user.ClaimedLinks)
+		assert.Equal(t, int64(1), user.ClaimedAPIKeys)
+
+		req, err := http.NewRequest(http.MethodGet, "/api/user/urls", nil)
+		assert.NoError(t, err)
+		req.Header.Set("X-API-Key", key.Key)
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "http://example.com/claimed")
+
+		rec = do(http.MethodPost, "/api/user/register", credentials, nil)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+
+		rec = do(http.MethodPost, "/api/user/login", `{"login":"alice","password":"wrong password"}`, nil)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		rec = do(http.MethodPost, "/api/user/login", `{"login":"alice","password":"correct horse"}`, nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		// Первую куку выдал запросу без токена JWTMiddleware, последняя — токен аккаунта
+		cookies := rec.Result().Cookies()
+		account := cookies[len(cookies)-1:]
+
+		rec = do(http.MethodGet, "/api/user/urls", "", account)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "http://example.com/claimed")
+
+		// Ссылки аккаунта нельзя забрать в другой аккаунт
+		rec = do(http.MethodPost, "/api/user/register", `{"login":"bob","password":"correct horse","claim_links":true}`, account)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+
+		rec = do(http.MethodPost, "/api/user/logout", "", account)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		cleared := rec.Result().Cookies()
+		assert.Equal(t, "", cleared[len(cleared)-1].Value)
+	})
+
+	t.Run("OIDC login", func(t *testing.T) {
+		noRedirects := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}}
+
+		login := func() (models.UserResponse, []*http.Cookie) {
+			req, err := http.NewRequest(http.MethodGet, "/api/auth/oidc/login", nil)
+			assert.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusFound, rec.Code)
+			state := rec.Result().Cookies()
+
+			// Страница входа провайдера сразу возвращает пользователя на callback
+			resp, err := noRedirects.Get(rec.Header().Get("Location"))
+			assert.NoError(t, err)
+			resp.Body.Close()
+			callback, err := url.Parse(resp.Header.Get("Location"))
+			assert.NoError(t, err)
+
+			req, err = http.NewRequest(http.MethodGet, callback.RequestURI(), nil)
+			assert.NoError(t, err)
+			for _, cookie := range state {
+				req.AddCookie(cookie)
+			}
+
+			rec = httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var user models.UserResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &user))
+			return user, rec.Result().Cookies()
+		}
+
+		first, cookies := login()
+		assert.Equal(t, "user-1", first.Login)
+		second, _ := login()
+		assert.Equal(t, first.ID, second.ID)
+
+		// Пользователя OIDC нет среди зарегистрированных, но его ссылки забрать тоже нельзя
+		var account []*http.Cookie
+		for _, cookie := range cookies {
+			if cookie.Name == auth.TokenCookieName {
+				account = append(account, cookie)
+			}
+		}
+		req, err := http.NewRequest(http.MethodPost, "/api/user/register",
+			strings.NewReader(`{"login":"carol","password":"correct horse","claim_links":true}`))
+		assert.NoError(t, err)
+		for _, cookie := range account {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+
+		// Callback без начатого входа отклоняется
+		req, err = http.NewRequest(http.MethodGet, "/api/auth/oidc/callback?code=x&state=y", nil)
+		assert.NoError(t, err)
+
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		// Токен пользователя, подписанный теми же ключами, не годится как состояние входа
+		req, err = http.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"http://example.com/oidc-state"}`))
+		assert.NoError(t, err)
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		req, err = http.NewRequest(http.MethodGet, "/api/auth/oidc/callback?code=x&state=", nil)
+		assert.NoError(t, err)
+		cookies = rec.Result().Cookies()
+		if !assert.NotEmpty(t, cookies) {
+			return
+		}
+		assert.Equal(t, auth.TokenCookieName, cookies[0].Name)
+		req.AddCookie(&http.Cookie{Name: "oidc_state", Value: cookies[0].Value})
+
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
 	t.Run("POST /api/shorten invalid URL", func(t *testing.T) {
 		requestBody, _ := json.Marshal(models.Request{URL: "invalid-url"})
 		req, err := http.NewRequest(http.MethodPost, "/api/shorten", bytes.NewReader(requestBody))
@@ -135,4 +618,354 @@ func TestMainHandler(t *testing.T) {
 
 		assert.Equal(t, http.StatusBadRequest, rec.Code)
 	})
+	t.Run("POST /api/shorten/batch mixed results", func(t *testing.T) {
+		postBatch := func(mode string, batch []models.BatchURLRequest) (int, []models.BatchURLResponse) {
+			requestBody, _ := json.Marshal(batch)
+			req, err := http.NewRequest(http.MethodPost, "/api/shorten/batch"+mode, bytes.NewReader(requestBody))
+			assert.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			var responses []models.BatchURLResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &responses))
+			return rec.Code, responses
+		}
+
+		code, responses := postBatch("", []models.BatchURLRequest{
+			{CorrelationID: "1", OriginalURL: "http://batch.example.com/1"},
+			{CorrelationID: "2", OriginalURL: "invalid-url"},
+		})
+		assert.Equal(t, http.StatusCreated, code)
+		assert.Len(t, responses, 2)
+		assert.Equal(t, models.BatchStatusCreated, responses[0].Status)
+		assert.Contains(t, responses[0].ShortURL, "http://localhost:8080/")
+		assert.Equal(t, models.BatchStatusInvalid, responses[1].Status)
+		assert.NotEmpty(t, responses[1].Error)
+
+		code, responses = postBatch("?mode=atomic", []models.BatchURLRequest{
+			{CorrelationID: "1", OriginalURL: "http://batch.example.com/2"},
+			{CorrelationID: "2", OriginalURL: "invalid-url"},
+		})
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Equal(t, models.BatchStatusAborted, responses[0].Status)
+		assert.Empty(t, responses[0].ShortURL)
+		assert.Equal(t, models.BatchStatusInvalid, responses[1].Status)
+
+		code, responses = postBatch("?mode=atomic", []models.BatchURLRequest{
+			{CorrelationID: "1", OriginalURL: "http://batch.example.com/3"},
+			{CorrelationID: "2", OriginalURL: "http://batch.example.com/4"},
+		})
+		assert.Equal(t, http.StatusCreated, code)
+		for _, response := range responses {
+			assert.Equal(t, models.BatchStatusCreated, response.Status)
+		}
+	})
+
+	t.Run("POST /api/shorten/batch unknown mode", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/api/shorten/batch?mode=eventually",
+			strings.NewReader(`[{"correlation_id":"1","original_url":"http://example.com"}]`))
+		assert.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("quotas", func(t *testing.T) {
+		batch := func(from, to int) []byte {
+			var requests []models.BatchURLRequest
+			for i := from; i < to; i++ {
+				requests = append(requests, models.BatchURLRequest{
+					CorrelationID: strconv.Itoa(i),
+					OriginalURL:   "http://quota.example.com/" + strconv.Itoa(i),
+				})
+			}
+			body, _ := json.Marshal(requests)
+			return body
+		}
+
+		req, err := http.NewRequest(http.MethodPost, "/api/shorten/batch", bytes.NewReader(batch(0, 11)))
+		assert.NoError(t, err)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		assert.JSONEq(t, `{"error":"Batch has too many items","quota":"batch_size","limit":10,"usage":0,"requested":11}`, rec.Body.String())
+
+		req, err = http.NewRequest(http.MethodPost, "/", strings.NewReader("http://example.com/"+strings.Repeat("a", 5000)))
+		assert.NoError(t, err)
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"quota":"request_bytes"`)
+
+		req, err = http.NewRequest(http.MethodPost, "/api/shorten/batch", bytes.NewReader(batch(0, 3)))
+		assert.NoError(t, err)
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		cookies := rec.Result().Cookies()
+
+		send := func(target string, body []byte) *httptest.ResponseRecorder {
+			method := http.MethodGet
+			if body != nil {
+				method = http.MethodPost
+			}
+			req, err := http.NewRequest(method, target, bytes.NewReader(body))
+			assert.NoError(t, err)
+			for _, cookie := range cookies {
+				req.AddCookie(cookie)
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			return rec
+		}
+
+		rec = send("/api/user/quota", nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"links":3,"max_links":5,"max_batch_size":10,"max_request_bytes":4096}`, rec.Body.String())
+
+		rec = send("/api/shorten/batch", batch(3, 6))
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.JSONEq(t, `{"error":"Link quota exceeded","quota":"links","limit":5,"usage":3,"requested":3}`, rec.Body.String())
+
+		rec = send("/api/shorten/batch", batch(3, 5))
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		rec = send("/api/shorten", []byte(`{"url":"http://quota.example.com/5"}`))
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		// Повторное сокращение не создаёт ссылку и отвечает существующей даже при исчерпанной квоте
+		rec = send("/api/shorten", []byte(`{"url":"http://quota.example.com/0"}`))
+		assert.Equal(t, http.StatusConflict, rec.Code)
+
+		rec = send("/", []byte("http://quota.example.com/1"))
+		assert.Equal(t, http.StatusConflict, rec.Code)
+
+		rec = send("/api/shorten/batch", batch(2, 4))
+		assert.Equal(t, http.StatusConflict, rec.Code)
+
+		rec = send("/api/shorten/batch", batch(4, 6))
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("GET /metrics", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/metrics", nil)
+		assert.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Result().Cookies())
+		body := rec.Body.String()
+		assert.Contains(t, body, `shortener_http_requests_total{method="POST",route="/api/shorten",status="201"}`)
+		assert.Contains(t, body, `shortener_http_requests_total{method="GET",route="/*",status="307"}`)
+		assert.Contains(t, body, `shortener_redirects_total{result="hit"}`)
+		assert.Contains(t, body, `shortener_redirects_total{result="not_found"}`)
+		assert.Contains(t, body, `shortener_store_operation_duration_seconds_count{backend="memory",operation="set"}`)
+		assert.Contains(t, body, "shortener_deletion_queue_depth")
+	})
+
+	t.Run("X-Request-ID", func(t *testing.T) {
+		get := func(requestID string) string {
+			req, err := http.NewRequest(http.MethodGet, "/ping", nil)
+			assert.NoError(t, err)
+			if requestID != "" {
+				req.Header.Set("X-Request-ID", requestID)
+			}
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			return rec.Header().Get("X-Request-ID")
+		}
+
+		assert.Equal(t, "req-42", get("req-42"))
+
+		generated := get("")
+		assert.NotEmpty(t, generated)
+		assert.NotEqual(t, generated, get(""))
+
+		// Идентификатор с управляющими символами заменяется новым
+		replaced := get("bad\tid")
+		assert.NotEmpty(t, replaced)
+		assert.NotEqual(t, "bad\tid", replaced)
+	})
+
+	t.Run("redirect_type", func(t *testing.T) {
+		shorten := func(body string) (int, string) {
+			req, err := http.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(body))
+			assert.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			var response models.Response
+			json.Unmarshal(rec.Body.Bytes(), &response)
+			return rec.Code, strings.TrimPrefix(response.Result, cfg.BaseURL)
+		}
+		follow := func(path string) *httptest.ResponseRecorder {
+			req, err := http.NewRequest(http.MethodGet, path, nil)
+			assert.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			return rec
+		}
+
+		code, path := shorten(`{"url":"http://example.com/permanent","redirect_type":308}`)
+		assert.Equal(t, http.StatusCreated, code)
+		rec := follow(path)
+		assert.Equal(t, http.StatusPermanentRedirect, rec.Code)
+		assert.Equal(t, "public, max-age=300", rec.Header().Get("Cache-Control"))
+
+		// Повторное сокращение возвращает ссылку, только если она ведёт запрошенным кодом
+		code, existing := shorten(`{"url":"http://example.com/permanent","redirect_type":308}`)
+		assert.Equal(t, http.StatusConflict, code)
+		assert.Equal(t, path, existing)
+		code, existing = shorten(`{"url":"http://example.com/permanent"}`)
+		assert.Equal(t, http.StatusConflict, code)
+		assert.Equal(t, path, existing)
+		code, existing = shorten(`{"url":"http://example.com/permanent","redirect_type":301}`)
+		assert.Equal(t, http.StatusConflict, code)
+		assert.Empty(t, existing)
+
+		code, path = shorten(`{"url":"http://example.com/permanent-ttl","redirect_type":301,"ttl_seconds":60}`)
+		assert.Equal(t, http.StatusCreated, code)
+		rec = follow(path)
+		assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+		assert.Regexp(t, `^public, max-age=(59|60)$`, rec.Header().Get("Cache-Control"))
+
+		code, path = shorten(`{"url":"http://example.com/temporary"}`)
+		assert.Equal(t, http.StatusCreated, code)
+		rec = follow(path)
+		assert.Equal(t, http.StatusTemporaryRedirect, rec.Code)
+		assert.Equal(t, "private, no-cache", rec.Header().Get("Cache-Control"))
+
+		code, _ = shorten(`{"url":"http://example.com/see-other","redirect_type":303}`)
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("PATCH /api/user/urls/{short}", func(t *testing.T) {
+		do := func(method, target, body string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+			req, err := http.NewRequest(method, target, strings.NewReader(body))
+			assert.NoError(t, err)
+			for _, cookie := range cookies {
+				req.AddCookie(cookie)
+			}
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			return rec
+		}
+
+		rec := do(http.MethodPost, "/api/shorten", `{"url":"http://example.com/edit-v1"}`, nil)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		owner := rec.Result().Cookies()
+		var created models.Response
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+		shortURL := strings.TrimPrefix(created.Result, cfg.BaseURL+"/")
+		target := "/api/user/urls/" + shortURL
+
+		rec = do(http.MethodPatch, target, `{"original_url":"http://example.com/stranger"}`, nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		rec = do(http.MethodPatch, target, `{"original_url":"not a url"}`, owner)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		// Адрес, уже сокращённый под другим кодом, занять нельзя
+		rec = do(http.MethodPost, "/", "http://example.com/edit-taken", owner)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		taken := rec.Body.String()
+		rec = do(http.MethodPatch, target, `{"original_url":"http://example.com/edit-taken"}`, owner)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		var conflict models.Response
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &conflict))
+		assert.Equal(t, taken, conflict.Result)
+
+		for _, originalURL := range []string{"http://example.com/edit-v2", "http://example.com/edit-v3"} {
+			rec = do(http.MethodPatch, target, `{"original_url":"`+originalURL+`"}`, owner)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			var updated models.URL
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &updated))
+			assert.Equal(t, created.Result, updated.ShortURL)
+			assert.Equal(t, originalURL, updated.OriginalURL)
+		}
+
+		rec = do(http.MethodGet, "/"+shortURL, "", nil)
+		assert.Equal(t, http.StatusTemporaryRedirect, rec.Code)
+		assert.Equal(t, "http://example.com/edit-v3", rec.Header().Get("Location"))
+
+		rec = do(http.MethodGet, target+"/history", "", nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		rec = do(http.MethodGet, target+"/history", "", owner)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var history []models.URLChange
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &history))
+		assert.Len(t, history, 2)
+		assert.Equal(t, "http://example.com/edit-v1", history[0].OldURL)
+		assert.Equal(t, "http://example.com/edit-v2", history[0].NewURL)
+		assert.Equal(t, "http://example.com/edit-v3", history[1].NewURL)
+
+		// Откат к значению до первого изменения тоже попадает в историю
+		rec = do(http.MethodPost, target+"/rollback", `{"change_id":"`+history[0].ID+`"}`, owner)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		rec = do(http.MethodGet, "/"+shortURL, "", nil)
+		assert.Equal(t, "http://example.com/edit-v1", rec.Header().Get("Location"))
+
+		rec = do(http.MethodPost, target+"/rollback", "", owner)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		rec = do(http.MethodGet, "/"+shortURL, "", nil)
+		assert.Equal(t, "http://example.com/edit-v3", rec.Header().Get("Location"))
+
+		rec = do(http.MethodPost, target+"/rollback", `{"change_id":"unknown"}`, owner)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		rec = do(http.MethodGet, target+"/history", "", owner)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &history))
+		assert.Len(t, history, 4)
+
+		// Истёкшую ссылку, как и при переходе, не редактируют и не откатывают
+		rec = do(http.MethodPost, "/api/shorten", `{"url":"http://example.com/edit-expiring","ttl_seconds":1}`, owner)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		var expiring models.Response
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &expiring))
+		expiringTarget := "/api/user/urls/" + strings.TrimPrefix(expiring.Result, cfg.BaseURL+"/")
+
+		time.Sleep(time.Second)
+
+		rec = do(http.MethodPatch, expiringTarget, `{"original_url":"http://example.com/edit-revived"}`, owner)
+		assert.Equal(t, http.StatusGone, rec.Code)
+		rec = do(http.MethodPost, expiringTarget+"/rollback", "", owner)
+		assert.Equal(t, http.StatusGone, rec.Code)
+	})
+
+	t.Run("token signed with an unknown key", func(t *testing.T) {
+		key, err := auth.NewHMACKey("retired", []byte(strings.Repeat("r", 32)))
+		assert.NoError(t, err)
+		keys, err := auth.NewKeySet("retired", key)
+		assert.NoError(t, err)
+		retired, err := auth.NewTokenManager(keys, time.Hour, 0, auth.ExpiredReissue, 0)
+		assert.NoError(t, err)
+		foreignToken, _, err := retired.Issue("old-user", true)
+		assert.NoError(t, err)
+
+		for _, token := range []string{foreignToken, "not-a-token"} {
+			req, err := http.NewRequest(http.MethodPost, "/", strings.NewReader("http://example.com/after-rotation"))
+			assert.NoError(t, err)
+			req.AddCookie(&http.Cookie{Name: auth.TokenCookieName, Value: token})
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			assert.NotEqual(t, http.StatusUnauthorized, rec.Code)
+
+			// Куку с непроверяемым токеном перезаписывает новая личность
+			cookies := rec.Result().Cookies()
+			if assert.Len(t, cookies, 1) {
+				assert.Equal(t, auth.TokenCookieName, cookies[0].Name)
+				assert.NotEqual(t, token, cookies[0].Value)
+				assert.NotEmpty(t, cookies[0].Value)
+			}
+		}
+	})
 }
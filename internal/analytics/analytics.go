@@ -0,0 +1,146 @@
+package analytics
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/learies/go-url-shortener/internal/models"
+)
+
+// Формат даты в дневной статистике переходов
+const dateLayout = "2006-01-02"
+
+// Маски анонимизации: у IPv4 обнуляется последний октет, у IPv6 — всё после /48
+var (
+	ipv4Mask = net.CIDRMask(24, 32)
+	ipv6Mask = net.CIDRMask(48, 128)
+)
+
+// NewClick создаёт событие перехода по короткому URL из входящего запроса
+func NewClick(r *http.Request, shortURL string) models.Click {
+	return models.Click{
+		ShortURL:  shortURL,
+		Timestamp: time.Now().UTC(),
+		Referrer:  r.Referer(),
+		UserAgent: r.UserAgent(),
+		IP:        AnonymizeIP(r.RemoteAddr),
+	}
+}
+
+// AnonymizeIP обрезает адрес клиента до подсети, чтобы не хранить его целиком
+func AnonymizeIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return ""
+	}
+
+	if ipv4 := ip.To4(); ipv4 != nil {
+		return ipv4.Mask(ipv4Mask).String()
+	}
+	return ip.Mask(ipv6Mask).String()
+}
+
+// Aggregate считает статистику переходов по короткому URL:
+// общее число, уникальных посетителей и разбивку по дням (UTC)
+func Aggregate(shortURL string, clicks []models.Click) *models.ClickStats {
+	counter := NewCounter()
+	for _, click := range clicks {
+		counter.Add(click)
+	}
+	return counter.Stats(shortURL)
+}
+
+// DaySnapshot статистика переходов за день в виде, пригодном для сохранения
+type DaySnapshot struct {
+	Date     string   `json:"date"`
+	Clicks   int64    `json:"clicks"`
+	Visitors []string `json:"visitors"`
+}
+
+// Counter накапливает статистику переходов по короткому URL без самих событий:
+// за каждый день (UTC) хранятся только число переходов и множество посетителей
+type Counter struct {
+	days map[string]*day
+}
+
+type day struct {
+	clicks   int64
+	visitors map[string]bool
+}
+
+func NewCounter() *Counter {
+	return &Counter{days: make(map[string]*day)}
+}
+
+// Add учитывает событие перехода
+func (c *Counter) Add(click models.Click) {
+	d := c.day(click.Timestamp.UTC().Format(dateLayout))
+	d.clicks++
+	d.visitors[click.IP] = true
+}
+
+// AddSnapshot учитывает сохранённую статистику за день
+func (c *Counter) AddSnapshot(snapshot DaySnapshot) {
+	d := c.day(snapshot.Date)
+	d.clicks += snapshot.Clicks
+	for _, visitor := range snapshot.Visitors {
+		d.visitors[visitor] = true
+	}
+}
+
+func (c *Counter) day(date string) *day {
+	d, ok := c.days[date]
+	if !ok {
+		d = &day{visitors: make(map[string]bool)}
+		c.days[date] = d
+	}
+	return d
+}
+
+// Snapshot возвращает накопленную статистику по дням в порядке дат
+func (c *Counter) Snapshot() []DaySnapshot {
+	snapshot := make([]DaySnapshot, 0, len(c.days))
+	for date, d := range c.days {
+		visitors := make([]string, 0, len(d.visitors))
+		for visitor := range d.visitors {
+			visitors = append(visitors, visitor)
+		}
+		sort.Strings(visitors)
+		snapshot = append(snapshot, DaySnapshot{Date: date, Clicks: d.clicks, Visitors: visitors})
+	}
+	sort.Slice(snapshot, func(i, j int) bool {
+		return snapshot[i].Date < snapshot[j].Date
+	})
+	return snapshot
+}
+
+// Stats возвращает статистику переходов по короткому URL
+func (c *Counter) Stats(shortURL string) *models.ClickStats {
+	stats := &models.ClickStats{
+		ShortURL: shortURL,
+		Daily:    []models.DailyClicks{},
+	}
+
+	visitors := make(map[string]bool)
+	for _, snapshot := range c.Snapshot() {
+		stats.TotalClicks += snapshot.Clicks
+		for _, visitor := range snapshot.Visitors {
+			visitors[visitor] = true
+		}
+		stats.Daily = append(stats.Daily, models.DailyClicks{
+			Date:           snapshot.Date,
+			Clicks:         snapshot.Clicks,
+			UniqueVisitors: int64(len(snapshot.Visitors)),
+		})
+	}
+	stats.UniqueVisitors = int64(len(visitors))
+
+	return stats
+}
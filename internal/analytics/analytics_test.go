@@ -0,0 +1,65 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/learies/go-url-shortener/internal/models"
+)
+
+func TestAnonymizeIP(t *testing.T) {
+	tests := []struct {
+		remoteAddr string
+		want       string
+	}{
+		{"192.0.2.123:54321", "192.0.2.0"},
+		{"192.0.2.123", "192.0.2.0"},
+		{"[2001:db8:abcd:12::1]:443", "2001:db8:abcd::"},
+		{"invalid", ""},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, AnonymizeIP(tt.remoteAddr), tt.remoteAddr)
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	day1 := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+
+	stats := Aggregate("abc", []models.Click{
+		{ShortURL: "abc", Timestamp: day2, IP: "192.0.2.0"},
+		{ShortURL: "abc", Timestamp: day1, IP: "192.0.2.0"},
+		{ShortURL: "abc", Timestamp: day1, IP: "192.0.2.0"},
+		{ShortURL: "abc", Timestamp: day1, IP: "198.51.100.0"},
+	})
+
+	assert.Equal(t, int64(4), stats.TotalClicks)
+	assert.Equal(t, int64(2), stats.UniqueVisitors)
+	assert.Equal(t, []models.DailyClicks{
+		{Date: "2024-10-01", Clicks: 3, UniqueVisitors: 2},
+		{Date: "2024-10-02", Clicks: 1, UniqueVisitors: 1},
+	}, stats.Daily)
+}
+
+func TestCounterSnapshot(t *testing.T) {
+	day := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	counter := NewCounter()
+	counter.Add(models.Click{ShortURL: "abc", Timestamp: day, IP: "192.0.2.0"})
+	counter.Add(models.Click{ShortURL: "abc", Timestamp: day, IP: "198.51.100.0"})
+
+	// Восстановленная из снимка статистика продолжает считаться с новыми событиями
+	restored := NewCounter()
+	for _, snapshot := range counter.Snapshot() {
+		restored.AddSnapshot(snapshot)
+	}
+	restored.Add(models.Click{ShortURL: "abc", Timestamp: day, IP: "192.0.2.0"})
+
+	stats := restored.Stats("abc")
+	assert.Equal(t, int64(3), stats.TotalClicks)
+	assert.Equal(t, int64(2), stats.UniqueVisitors)
+	assert.Equal(t, []models.DailyClicks{{Date: "2024-10-01", Clicks: 3, UniqueVisitors: 2}}, stats.Daily)
+}
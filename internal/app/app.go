@@ -0,0 +1,355 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/learies/go-url-shortener/config"
+	"github.com/learies/go-url-shortener/internal/auth"
+	"github.com/learies/go-url-shortener/internal/logger"
+	"github.com/learies/go-url-shortener/internal/metrics"
+	"github.com/learies/go-url-shortener/internal/models"
+	"github.com/learies/go-url-shortener/internal/ratelimit"
+	"github.com/learies/go-url-shortener/internal/router"
+	"github.com/learies/go-url-shortener/internal/shortener"
+	"github.com/learies/go-url-shortener/internal/store"
+	"github.com/learies/go-url-shortener/internal/store/dbstore"
+	"github.com/learies/go-url-shortener/internal/tracing"
+	"github.com/learies/go-url-shortener/internal/worker"
+)
+
+// App сервис сокращения URL вместе с хранилищем и фоновыми обработчиками
+type App struct {
+	cfg     config.Config
+	store   store.Store
+	clicks  *worker.ClickRecorder
+	deleter *worker.Deleter
+	server  *http.Server
+	// adminServer отдельный сервер для /metrics, nil — метрики на основном сервере
+	adminServer *http.Server
+	// requests обрабатываемые запросы: хранилище закрывается только после них.
+	// cancelRequests отменяет контексты запросов, если они не успели завершиться к сроку остановки
+	requests       requestTracker
+	cancelRequests context.CancelFunc
+
+	stopTracing func(context.Context) error
+
+	stopSweeper context.CancelFunc
+	sweeperDone chan struct{}
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// New создаёт хранилище, запускает фоновые обработчики и собирает HTTP-сервер
+func New(cfg config.Config) (*App, error) {
+	urlShortener, err := shortener.NewGenerator(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if !models.ValidRedirectType(cfg.RedirectCode) {
+		return nil, fmt.Errorf("unsupported redirect code %d", cfg.RedirectCode)
+	}
+
+	keys, err := auth.LoadKeySet(cfg.JWTSecret, cfg.JWTKeysFile, cfg.DevMode)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := auth.NewTokenManager(keys, cfg.TokenLifetime, cfg.TokenRenewBefore, cfg.ExpiredTokenPolicy, cfg.ExpiredTokenGrace)
+	if err != nil {
+		return nil, err
+	}
+
+	stopTracing, err := tracing.Setup(context.Background(), cfg.TracingExporter, cfg.TracingOTLPEndpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	urlStore, err := store.NewStore(cfg)
+	if err != nil {
+		return nil, errors.Join(err, stopTracing(context.Background()))
+	}
+
+	if gen, ok := urlShortener.(shortener.Sequential); ok {
+		if err := resumeGenerator(context.Background(), gen, urlStore, "short_url_"+cfg.ShortURLGenerator); err != nil {
+			return nil, errors.Join(err, urlStore.Close(), stopTracing(context.Background()))
+		}
+	}
+
+	limiter, err := newLimiter(cfg.RateLimitBackend, urlStore)
+	if err != nil {
+		return nil, errors.Join(err, urlStore.Close(), stopTracing(context.Background()))
+	}
+
+	m := metrics.New()
+	if dbStore, ok := urlStore.(*dbstore.DBStore); ok {
+		m.RegisterDBStats(dbStore.DB)
+	}
+	urlStore = store.Instrument(urlStore, m.ObserveStoreOp)
+
+	app := &App{
+		cfg:         cfg,
+		store:       urlStore,
+		stopTracing: stopTracing,
+		clicks:      worker.NewClickRecorder(urlStore, cfg.ClickBatchSize, cfg.ClickFlushInterval),
+		deleter:     worker.NewDeleter(urlStore, cfg.DeleteBatchSize, cfg.DeleteFlushInterval),
+		sweeperDone: make(chan struct{}),
+	}
+
+	m.RegisterDeletionQueue(app.deleter.Len)
+
+	var sweeperCtx context.Context
+	sweeperCtx, app.stopSweeper = context.WithCancel(context.Background())
+	go func() {
+		defer close(app.sweeperDone)
+		worker.RunExpirySweeper(sweeperCtx, urlStore, cfg.ExpirySweepInterval, cfg.ExpiredURLRetention)
+	}()
+
+	var oidc *auth.OIDCProvider
+	if cfg.OIDCIssuer != "" {
+		oidc = auth.NewOIDCProvider(auth.OIDCConfig{
+			Issuer:       cfg.OIDCIssuer,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.BaseURL + "/api/auth/oidc/callback",
+		}, nil)
+	}
+
+	handler := router.NewRouter(cfg, router.Dependencies{
+		Store:     urlStore,
+		Shortener: urlShortener,
+		Clicks:    app.clicks,
+		Deleter:   app.deleter,
+		Tokens:    tokens,
+		OIDC:      oidc,
+		Limiter:   limiter,
+		Metrics:   m,
+	})
+	requestsCtx, cancelRequests := context.WithCancel(context.Background())
+	app.cancelRequests = cancelRequests
+	app.server = &http.Server{
+		Addr:        cfg.Address,
+		Handler:     app.requests.track(handler),
+		BaseContext: func(net.Listener) context.Context { return requestsCtx },
+	}
+	if cfg.MetricsAddress != "" {
+		admin := http.NewServeMux()
+		admin.Handle("/metrics", m.Handler())
+		app.adminServer = &http.Server{
+			Addr:    cfg.MetricsAddress,
+			Handler: admin,
+		}
+	}
+	return app, nil
+}
+
+// resumeGenerator подключает счётчик генератора к постоянному счётчику name в хранилище.
+// Если тот ещё не заводился (сервис обновлён с версии, где счётчик жил только в памяти),
+// он один раз продвигается за наибольший из сохранённых кодов генератора
+func resumeGenerator(ctx context.Context, gen shortener.Sequential, s store.Store, name string) error {
+	current, err := s.ReserveSequence(ctx, name, 0)
+	if err != nil {
+		return err
+	}
+
+	if current == 0 {
+		var last uint64
+		err := s.EachGeneratedShortURL(ctx, func(shortURL string) {
+			if position, ok := gen.Position(shortURL); ok && position > last {
+				last = position
+			}
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := s.ReserveSequence(ctx, name, last); err != nil {
+			return err
+		}
+		logger.Log.Info("Initialized short URL counter from stored codes", "position", last)
+	}
+
+	return gen.Use(storeSequence{store: s, name: name})
+}
+
+// storeSequence постоянный счётчик name в хранилище
+type storeSequence struct {
+	store store.Store
+	name  string
+}
+
+func (seq storeSequence) Reserve(size uint64) (uint64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return seq.store.ReserveSequence(ctx, seq.name, size)
+}
+
+// newLimiter создаёт лимитер запросов для выбранного хранилища счётчиков.
+// Пустое значение и none отключают ограничение
+func newLimiter(backend string, s store.Store) (ratelimit.Limiter, error) {
+	switch backend {
+	case "", "none":
+		return nil, nil
+	case "memory":
+		return ratelimit.NewMemoryLimiter(), nil
+	case "postgres":
+		dbStore, ok := s.(*dbstore.DBStore)
+		if !ok {
+			return nil, errors.New("postgres rate limit backend requires DATABASE_DSN")
+		}
+		return ratelimit.NewPostgresLimiter(dbStore.DB), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit backend %q", backend)
+	}
+}
+
+// Handler возвращает обработчик HTTP API сервиса
+func (app *App) Handler() http.Handler {
+	return app.server.Handler
+}
+
+// Run обслуживает запросы до отмены ctx, затем ждёт завершения обрабатываемых
+// запросов не дольше cfg.ShutdownTimeout и останавливает сервис. Запросы, не успевшие
+// к сроку, отменяются, и хранилище закрывается только после их завершения
+func (app *App) Run(ctx context.Context) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Log.Info("Starting server", "address", app.cfg.Address)
+		serveErr <- app.server.ListenAndServe()
+	}()
+
+	if app.adminServer != nil {
+		go func() {
+			logger.Log.Info("Starting metrics server", "address", app.cfg.MetricsAddress)
+			if err := app.adminServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				logger.Log.Error("Metrics server failed", "error", err)
+			}
+		}()
+	}
+
+	select {
+	case err := <-serveErr:
+		return errors.Join(err, app.Close())
+	case <-ctx.Done():
+	}
+
+	logger.Log.Info("Shutting down server", "timeout", app.cfg.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), app.cfg.ShutdownTimeout)
+	defer cancel()
+
+	err := app.server.Shutdown(shutdownCtx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		logger.Log.Warn("Shutdown deadline exceeded, dropping remaining connections")
+		err = app.server.Close()
+		// Close не ждёт обработчики: отменяем их контексты, чтобы операции
+		// с хранилищем прервались, а Close дождётся их завершения
+		app.cancelRequests()
+	}
+	if serveErr := <-serveErr; !errors.Is(serveErr, http.ErrServerClosed) {
+		err = errors.Join(err, serveErr)
+	}
+
+	return errors.Join(err, app.Close())
+}
+
+// Close останавливает сервер метрик и фоновые обработчики, закрывает хранилище
+// и сбрасывает накопленные спаны трассировки.
+// Очередь удаления и конвейер переходов дописываются до закрытия хранилища.
+// Повторные вызовы безопасны
+func (app *App) Close() error {
+	app.closeOnce.Do(func() {
+		if app.adminServer != nil {
+			app.adminServer.Close()
+		}
+
+		app.requests.wait()
+		app.cancelRequests()
+
+		app.stopSweeper()
+		<-app.sweeperDone
+
+		app.deleter.Close()
+		app.clicks.Close()
+
+		app.closeErr = app.store.Close()
+		if app.closeErr != nil {
+			logger.Log.Error("Failed to close store", "error", app.closeErr)
+		}
+
+		// Спаны, накопленные до остановки, отправляются экспортёру
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := app.stopTracing(ctx); err != nil {
+			logger.Log.Error("Failed to flush traces", "error", err)
+			app.closeErr = errors.Join(app.closeErr, err)
+		}
+
+		if app.closeErr == nil {
+			logger.Log.Info("Service stopped")
+		}
+	})
+	return app.closeErr
+}
+
+// requestTracker считает обрабатываемые запросы. После wait новые запросы
+// отклоняются с 503, чтобы не обращаться к закрываемому хранилищу
+type requestTracker struct {
+	mu       sync.Mutex
+	active   int
+	draining bool
+	idle     chan struct{}
+}
+
+// track оборачивает обработчик подсчётом запросов
+func (t *requestTracker) track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !t.start() {
+			http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		defer t.finish()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (t *requestTracker) start() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.draining {
+		return false
+	}
+	t.active++
+	return true
+}
+
+func (t *requestTracker) finish() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.active--
+	if t.draining && t.active == 0 {
+		close(t.idle)
+	}
+}
+
+// wait перестаёт принимать запросы и ждёт завершения обрабатываемых
+func (t *requestTracker) wait() {
+	t.mu.Lock()
+	if !t.draining {
+		t.draining = true
+		t.idle = make(chan struct{})
+		if t.active == 0 {
+			close(t.idle)
+		}
+	}
+	idle := t.idle
+	t.mu.Unlock()
+
+	<-idle
+}
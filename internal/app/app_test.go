@@ -0,0 +1,142 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/learies/go-url-shortener/config"
+	"github.com/learies/go-url-shortener/internal/logger"
+	"github.com/learies/go-url-shortener/internal/models"
+	"github.com/learies/go-url-shortener/internal/shortener"
+	"github.com/learies/go-url-shortener/internal/store/filestore"
+	"github.com/learies/go-url-shortener/internal/store/memstore"
+)
+
+func TestRunShutdown(t *testing.T) {
+	require.NoError(t, logger.Initialize("error"))
+
+	filePath := filepath.Join(t.TempDir(), "storage.json")
+	cfg := config.Config{
+		Address:             "127.0.0.1:0",
+		BaseURL:             "http://localhost:8080",
+		FileStoragePath:     filePath,
+		ShortURLGenerator:   "hash",
+		ShortURLLength:      8,
+		ClickBatchSize:      100,
+		ClickFlushInterval:  time.Hour,
+		DeleteBatchSize:     100,
+		DeleteFlushInterval: time.Hour,
+		ShutdownTimeout:     time.Second,
+		JWTSecret:           strings.Repeat("s", 32),
+		TokenLifetime:       time.Hour,
+		ExpiredTokenPolicy:  "reissue",
+		RedirectCode:        307,
+	}
+
+	service, err := New(cfg)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, service.store.Set(ctx, models.Storage{ShortURL: "abc", OriginalURL: "http://example.com", UserID: "user"}))
+	require.NoError(t, service.deleter.Enqueue(ctx, models.UserURL{UserID: "user", ShortURL: "abc"}))
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() { done <- service.Run(runCtx) }()
+	cancel()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("service did not stop")
+	}
+	assert.NoError(t, service.Close())
+
+	// Удаление из очереди применено до закрытия хранилища
+	reloaded, err := filestore.NewFileStore(filePath, 0)
+	require.NoError(t, err)
+	defer reloaded.Close()
+
+	s, exists := reloaded.Get(ctx, "abc")
+	require.True(t, exists)
+	assert.True(t, s.DeletedFlag)
+}
+
+func TestRequestTracker(t *testing.T) {
+	var tracker requestTracker
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := tracker.track(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	inFlight := httptest.NewRecorder()
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		handler.ServeHTTP(inFlight, httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+	<-started
+
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		tracker.wait()
+	}()
+
+	require.Eventually(t, func() bool {
+		tracker.mu.Lock()
+		defer tracker.mu.Unlock()
+		return tracker.draining
+	}, time.Second, 10*time.Millisecond)
+
+	// Пока запрос обрабатывается, wait не возвращается, а новые запросы отклоняются
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	select {
+	case <-drained:
+		t.Fatal("wait returned before the request finished")
+	default:
+	}
+
+	close(release)
+	<-served
+	<-drained
+	assert.Equal(t, http.StatusNoContent, inFlight.Code)
+}
+
+func TestResumeGenerator(t *testing.T) {
+	require.NoError(t, logger.Initialize("error"))
+	ctx := context.Background()
+
+	urlStore := memstore.NewMemStore()
+	require.NoError(t, urlStore.Set(ctx, models.Storage{ShortURL: "000000a", OriginalURL: "http://example.com/a", UserID: "user"}))
+	require.NoError(t, urlStore.Set(ctx, models.Storage{ShortURL: "000000b", OriginalURL: "http://example.com/b", UserID: "user"}))
+	// Алиас, похожий на код счётчика, и коды другой длины не сдвигают счётчик
+	require.NoError(t, urlStore.SetAlias(ctx, models.Storage{ShortURL: "zzzzzzz", OriginalURL: "http://example.com/z", UserID: "user"}))
+	require.NoError(t, urlStore.Set(ctx, models.Storage{ShortURL: "zzzz", OriginalURL: "http://example.com/hash", UserID: "user"}))
+
+	gen := shortener.NewCounterGenerator(7)
+	require.NoError(t, resumeGenerator(ctx, gen, urlStore, "short_url_counter"))
+	assert.Equal(t, "000000c", gen.GenerateShortURL("", 0))
+
+	// Дальше позиция берётся из сохранённого счётчика, а не из кодов
+	require.NoError(t, urlStore.Set(ctx, models.Storage{ShortURL: "000zzzz", OriginalURL: "http://example.com/foreign", UserID: "user"}))
+	restarted := shortener.NewCounterGenerator(7)
+	require.NoError(t, resumeGenerator(ctx, restarted, urlStore, "short_url_counter"))
+	position, ok := restarted.Position(restarted.GenerateShortURL("", 0))
+	require.True(t, ok)
+	assert.Equal(t, uint64(37+1000+1), position)
+}
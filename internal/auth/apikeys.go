@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// APIKeyPrefix префикс ключей доступа, по которому их легко узнать в логах и конфигах
+const APIKeyPrefix = "shk_"
+
+// Длина видимой части ключа, по которой пользователь отличает свои ключи
+const apiKeyDisplayLength = len(APIKeyPrefix) + 8
+
+// GenerateAPIKey создаёт новый ключ доступа. Возвращает сам ключ, который показывается
+// пользователю один раз, его видимую часть и хэш для хранения
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", "", err
+	}
+
+	key = APIKeyPrefix + base64.RawURLEncoding.EncodeToString(random)
+	return key, key[:apiKeyDisplayLength], HashAPIKey(key), nil
+}
+
+// HashAPIKey вычисляет хэш ключа доступа. Ключи случайные и длинные,
+// поэтому медленное хэширование, как для паролей, не нужно
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsAPIKey сообщает, похожа ли строка на ключ доступа
+func IsAPIKey(s string) bool {
+	return strings.HasPrefix(s, APIKeyPrefix) && len(s) > apiKeyDisplayLength
+}
@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Поддерживаемые алгоритмы подписи токенов
+const (
+	AlgHS256 = "HS256"
+	AlgEdDSA = "EdDSA"
+	AlgRS256 = "RS256"
+)
+
+var (
+	// ErrUnknownKey токен подписан ключом, которого нет среди ключей проверки
+	ErrUnknownKey = errors.New("unknown token signing key")
+	// ErrNoSigningKey среди ключей нет ключа, которым можно подписывать токены
+	ErrNoSigningKey = errors.New("no signing key configured")
+	// ErrKeysNotConfigured не задан ни секрет, ни файл ключей подписи токенов
+	ErrKeysNotConfigured = errors.New("token signing keys are not configured: set JWT_SECRET or JWT_KEYS_FILE")
+)
+
+// Key ключ подписи токенов. Для асимметричных алгоритмов ключ без закрытой части
+// годится только для проверки подписи
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+
+	signKey   any
+	verifyKey any
+}
+
+// NewHMACKey создаёт симметричный ключ HS256
+func NewHMACKey(id string, secret []byte) (*Key, error) {
+	if len(secret) < 32 {
+		return nil, fmt.Errorf("key %q: HMAC secret must be at least 32 bytes", id)
+	}
+	return &Key{ID: id, Method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret}, nil
+}
+
+// NewPrivateKey создаёт ключ подписи по закрытому ключу Ed25519 или RSA
+func NewPrivateKey(id string, privateKey crypto.Signer) (*Key, error) {
+	switch k := privateKey.(type) {
+	case ed25519.PrivateKey:
+		return &Key{ID: id, Method: jwt.SigningMethodEdDSA, signKey: k, verifyKey: k.Public()}, nil
+	case *rsa.PrivateKey:
+		return &Key{ID: id, Method: jwt.SigningMethodRS256, signKey: k, verifyKey: &k.PublicKey}, nil
+	default:
+		return nil, fmt.Errorf("key %q: unsupported private key type %T", id, privateKey)
+	}
+}
+
+// NewPublicKey создаёт ключ, пригодный только для проверки подписи
+func NewPublicKey(id string, publicKey crypto.PublicKey) (*Key, error) {
+	switch k := publicKey.(type) {
+	case ed25519.PublicKey:
+		return &Key{ID: id, Method: jwt.SigningMethodEdDSA, verifyKey: k}, nil
+	case *rsa.PublicKey:
+		return &Key{ID: id, Method: jwt.SigningMethodRS256, verifyKey: k}, nil
+	default:
+		return nil, fmt.Errorf("key %q: unsupported public key type %T", id, publicKey)
+	}
+}
+
+// CanSign сообщает, можно ли подписывать ключом токены
+func (k *Key) CanSign() bool {
+	return k.signKey != nil
+}
+
+// KeySet набор ключей: одним подписываются новые токены, все остальные
+// принимаются при проверке, что позволяет менять ключ без разлогинивания пользователей
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+	order   []string
+}
+
+// NewKeySet создаёт набор ключей, в котором новые токены подписываются ключом signingID
+func NewKeySet(signingID string, keys ...*Key) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*Key, len(keys))}
+	for _, key := range keys {
+		if key.ID == "" {
+			return nil, errors.New("key ID must not be empty")
+		}
+		if _, exists := ks.keys[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate key ID %q", key.ID)
+		}
+		ks.keys[key.ID] = key
+		ks.order = append(ks.order, key.ID)
+	}
+
+	signing, ok := ks.keys[signingID]
+	if !ok || !signing.CanSign() {
+		return nil, fmt.Errorf("%w: %q", ErrNoSigningKey, signingID)
+	}
+	ks.signing = signing
+	return ks, nil
+}
+
+// Sign подписывает claims активным ключом и указывает его в заголовке kid
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.signing.Method, claims)
+	token.Header["kid"] = ks.signing.ID
+	return token.SignedString(ks.signing.signKey)
+}
+
+// Parse проверяет подпись токена ключом из заголовка kid и заполняет claims
+func (ks *KeySet) Parse(tokenString string, claims jwt.Claims, opts ...jwt.ParserOption) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, ok := ks.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+		}
+		// Алгоритм определяется ключом, а не заголовком токена
+		if t.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %q for key %q", t.Method.Alg(), kid)
+		}
+		return key.verifyKey, nil
+	}, opts...)
+}
+
+// JWK открытый ключ в формате JSON Web Key (RFC 7517)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+// JWKS набор открытых ключей для проверки токенов другими сервисами
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS возвращает открытые части асимметричных ключей набора.
+// Симметричные ключи не публикуются
+func (ks *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, id := range ks.order {
+		key := ks.keys[id]
+		switch k := key.verifyKey.(type) {
+		case ed25519.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				KeyType:   "OKP",
+				KeyID:     key.ID,
+				Algorithm: AlgEdDSA,
+				Use:       "sig",
+				Curve:     "Ed25519",
+				X:         base64.RawURLEncoding.EncodeToString(k),
+			})
+		case *rsa.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				KeyType:   "RSA",
+				KeyID:     key.ID,
+				Algorithm: AlgRS256,
+				Use:       "sig",
+				N:         base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+				E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+			})
+		}
+	}
+	return jwks
+}
@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/learies/go-url-shortener/internal/logger"
+)
+
+func TestKeySetRotation(t *testing.T) {
+	oldKey, err := NewHMACKey("old", []byte(strings.Repeat("o", 32)))
+	require.NoError(t, err)
+	newKey, err := NewHMACKey("new", []byte(strings.Repeat("n", 32)))
+	require.NoError(t, err)
+
+	before, err := NewKeySet("old", oldKey)
+	require.NoError(t, err)
+	oldToken, err := before.Sign(jwt.RegisteredClaims{Subject: "user"})
+	require.NoError(t, err)
+
+	after, err := NewKeySet("new", newKey, oldKey)
+	require.NoError(t, err)
+
+	t.Run("accepts tokens of previous key", func(t *testing.T) {
+		var claims jwt.RegisteredClaims
+		token, err := after.Parse(oldToken, &claims)
+		require.NoError(t, err)
+		assert.True(t, token.Valid)
+		assert.Equal(t, "user", claims.Subject)
+	})
+
+	t.Run("signs with active key", func(t *testing.T) {
+		tokenString, err := after.Sign(jwt.RegisteredClaims{Subject: "user"})
+		require.NoError(t, err)
+
+		token, err := after.Parse(tokenString, &jwt.RegisteredClaims{})
+		require.NoError(t, err)
+		assert.Equal(t, "new", token.Header["kid"])
+	})
+
+	t.Run("rejects removed key", func(t *testing.T) {
+		only, err := NewKeySet("new", newKey)
+		require.NoError(t, err)
+
+		_, err = only.Parse(oldToken, &jwt.RegisteredClaims{})
+		assert.ErrorIs(t, err, ErrUnknownKey)
+	})
+
+	t.Run("rejects tokens without kid", func(t *testing.T) {
+		tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{}).
+			SignedString([]byte(strings.Repeat("n", 32)))
+		require.NoError(t, err)
+
+		_, err = after.Parse(tokenString, &jwt.RegisteredClaims{})
+		assert.ErrorIs(t, err, ErrUnknownKey)
+	})
+
+	t.Run("rejects short secrets", func(t *testing.T) {
+		_, err := NewHMACKey("short", []byte("qwerty"))
+		assert.Error(t, err)
+	})
+}
+
+func TestKeySetAsymmetric(t *testing.T) {
+	_, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	edKey, err := NewPrivateKey("ed", edPrivate)
+	require.NoError(t, err)
+
+	ks, err := NewKeySet("ed", edKey)
+	require.NoError(t, err)
+	tokenString, err := ks.Sign(jwt.RegisteredClaims{Subject: "user"})
+	require.NoError(t, err)
+
+	t.Run("verifies with public key only", func(t *testing.T) {
+		publicKey, err := NewPublicKey("ed", edPrivate.Public())
+		require.NoError(t, err)
+		assert.False(t, publicKey.CanSign())
+
+		verifier := &KeySet{keys: map[string]*Key{"ed": publicKey}}
+		token, err := verifier.Parse(tokenString, &jwt.RegisteredClaims{})
+		require.NoError(t, err)
+		assert.True(t, token.Valid)
+
+		_, err = NewKeySet("ed", publicKey)
+		assert.ErrorIs(t, err, ErrNoSigningKey)
+	})
+
+	t.Run("rejects algorithm substitution", func(t *testing.T) {
+		// Подпись HMAC с открытым ключом в качестве секрета не должна приниматься
+		forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Subject: "admin"})
+		forged.Header["kid"] = "ed"
+		forgedString, err := forged.SignedString([]byte(edPrivate.Public().(ed25519.PublicKey)))
+		require.NoError(t, err)
+
+		_, err = ks.Parse(forgedString, &jwt.RegisteredClaims{})
+		assert.Error(t, err)
+	})
+
+	t.Run("publishes JWKS", func(t *testing.T) {
+		hmacKey, err := NewHMACKey("hmac", []byte(strings.Repeat("h", 32)))
+		require.NoError(t, err)
+		ks, err := NewKeySet("ed", edKey, hmacKey)
+		require.NoError(t, err)
+
+		jwks := ks.JWKS()
+		require.Len(t, jwks.Keys, 1)
+		assert.Equal(t, "ed", jwks.Keys[0].KeyID)
+		assert.Equal(t, "OKP", jwks.Keys[0].KeyType)
+		assert.NotEmpty(t, jwks.Keys[0].X)
+	})
+}
+
+func TestLoadKeySet(t *testing.T) {
+	require.NoError(t, logger.Initialize("error"))
+
+	t.Run("random secret in dev mode", func(t *testing.T) {
+		ks, err := LoadKeySet("", "", true)
+		require.NoError(t, err)
+		_, err = ks.Sign(jwt.RegisteredClaims{})
+		assert.NoError(t, err)
+	})
+
+	t.Run("no key outside dev mode", func(t *testing.T) {
+		_, err := LoadKeySet("", "", false)
+		assert.ErrorIs(t, err, ErrKeysNotConfigured)
+	})
+
+	t.Run("secret", func(t *testing.T) {
+		secret := strings.Repeat("s", 32)
+		first, err := LoadKeySet(secret, "", false)
+		require.NoError(t, err)
+		second, err := LoadKeySet(secret, "", false)
+		require.NoError(t, err)
+
+		// Токены переживают перезапуск с тем же секретом
+		tokenString, err := first.Sign(jwt.RegisteredClaims{})
+		require.NoError(t, err)
+		_, err = second.Parse(tokenString, &jwt.RegisteredClaims{})
+		assert.NoError(t, err)
+	})
+
+	t.Run("keys file", func(t *testing.T) {
+		dir := t.TempDir()
+
+		rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		writePEM(t, filepath.Join(dir, "rsa.pem"), "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+
+		edPublic, _, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		der, err := x509.MarshalPKIXPublicKey(edPublic)
+		require.NoError(t, err)
+		writePEM(t, filepath.Join(dir, "ed.pub.pem"), "PUBLIC KEY", der)
+
+		keysFile := filepath.Join(dir, "keys.json")
+		require.NoError(t, os.WriteFile(keysFile, []byte(`{
+			"active": "rsa",
+			"keys": [
+				{"kid": "rsa", "alg": "RS256", "private_key_file": "rsa.pem"},
+				{"kid": "ed-old", "alg": "EdDSA", "public_key_file": "ed.pub.pem"},
+				{"kid": "hmac-old", "alg": "HS256", "secret": "`+strings.Repeat("x", 32)+`"}
+			]
+		}`), 0600))
+
+		ks, err := LoadKeySet("ignored", keysFile, false)
+		require.NoError(t, err)
+
+		tokenString, err := ks.Sign(jwt.RegisteredClaims{})
+		require.NoError(t, err)
+		token, err := ks.Parse(tokenString, &jwt.RegisteredClaims{})
+		require.NoError(t, err)
+		assert.Equal(t, AlgRS256, token.Method.Alg())
+		assert.Len(t, ks.JWKS().Keys, 2)
+	})
+
+	t.Run("algorithm mismatch", func(t *testing.T) {
+		dir := t.TempDir()
+		_, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		der, err := x509.MarshalPKCS8PrivateKey(edPrivate)
+		require.NoError(t, err)
+		writePEM(t, filepath.Join(dir, "ed.pem"), "PRIVATE KEY", der)
+
+		keysFile := filepath.Join(dir, "keys.json")
+		require.NoError(t, os.WriteFile(keysFile, []byte(`{
+			"active": "ed",
+			"keys": [{"kid": "ed", "alg": "RS256", "private_key_file": "ed.pem"}]
+		}`), 0600))
+
+		_, err = LoadKeySet("", keysFile, false)
+		assert.Error(t, err)
+	})
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	require.NoError(t, os.WriteFile(path, data, 0600))
+}
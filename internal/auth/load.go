@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/learies/go-url-shortener/internal/logger"
+)
+
+// keysFile описание ключей в файле JWT_KEYS_FILE. Пути к PEM-файлам
+// считаются относительно каталога самого файла
+type keysFile struct {
+	Active string        `json:"active"`
+	Keys   []keyFileItem `json:"keys"`
+}
+
+type keyFileItem struct {
+	ID             string `json:"kid"`
+	Algorithm      string `json:"alg"`
+	Secret         string `json:"secret,omitempty"`
+	PrivateKeyFile string `json:"private_key_file,omitempty"`
+	PublicKeyFile  string `json:"public_key_file,omitempty"`
+}
+
+// LoadKeySet загружает ключи подписи токенов: из файла keysFile, если он задан,
+// иначе из секрета secret. Без настроек случайный секрет генерируется только
+// при allowRandom (режим разработки): выданные с ним токены перестают приниматься
+// после перезапуска сервиса. Иначе возвращается ErrKeysNotConfigured
+func LoadKeySet(secret, keysFile string, allowRandom bool) (*KeySet, error) {
+	if keysFile != "" {
+		return loadKeysFile(keysFile)
+	}
+
+	if secret == "" {
+		if !allowRandom {
+			return nil, ErrKeysNotConfigured
+		}
+		logger.Log.Warn("JWT signing secret is not configured, using a random one")
+		random := make([]byte, 32)
+		if _, err := rand.Read(random); err != nil {
+			return nil, err
+		}
+		secret = string(random)
+	}
+
+	key, err := NewHMACKey(secretKeyID(secret), []byte(secret))
+	if err != nil {
+		return nil, err
+	}
+	return NewKeySet(key.ID, key)
+}
+
+// secretKeyID вычисляет kid секрета, не раскрывая сам секрет
+func secretKeyID(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:4])
+}
+
+func loadKeysFile(path string) (*KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file keysFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse keys file %s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	keys := make([]*Key, 0, len(file.Keys))
+	for _, item := range file.Keys {
+		key, err := item.load(dir)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return NewKeySet(file.Active, keys...)
+}
+
+func (item keyFileItem) load(dir string) (*Key, error) {
+	switch item.Algorithm {
+	case AlgHS256:
+		return NewHMACKey(item.ID, []byte(item.Secret))
+	case AlgEdDSA, AlgRS256:
+	default:
+		return nil, fmt.Errorf("key %q: unsupported algorithm %q", item.ID, item.Algorithm)
+	}
+
+	var key *Key
+	switch {
+	case item.PrivateKeyFile != "":
+		block, err := readPEM(dir, item.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		privateKey, err := parsePrivateKey(block)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", item.ID, err)
+		}
+		if key, err = NewPrivateKey(item.ID, privateKey); err != nil {
+			return nil, err
+		}
+	case item.PublicKeyFile != "":
+		block, err := readPEM(dir, item.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", item.ID, err)
+		}
+		if key, err = NewPublicKey(item.ID, publicKey); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("key %q: private_key_file or public_key_file is required", item.ID)
+	}
+
+	if key.Method.Alg() != item.Algorithm {
+		return nil, fmt.Errorf("key %q: key type does not match algorithm %q", item.ID, item.Algorithm)
+	}
+	return key, nil
+}
+
+func readPEM(dir, path string) (*pem.Block, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", path)
+	}
+	return block, nil
+}
+
+func parsePrivateKey(block *pem.Block) (crypto.Signer, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("private key cannot sign")
+	}
+	return signer, nil
+}
@@ -0,0 +1,123 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/learies/go-url-shortener/internal/auth"
+	"github.com/learies/go-url-shortener/internal/auth/oidctest"
+)
+
+func TestOIDCProvider(t *testing.T) {
+	idp := oidctest.NewServer("shortener")
+	defer idp.Close()
+
+	provider := auth.NewOIDCProvider(auth.OIDCConfig{
+		Issuer:      idp.Issuer(),
+		ClientID:    "shortener",
+		RedirectURL: "http://localhost:8080/api/auth/oidc/callback",
+	}, nil)
+	ctx := context.Background()
+
+	noRedirects := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	// authorize проходит страницу входа провайдера и возвращает код авторизации
+	authorize := func(t *testing.T, nonce, challenge string) string {
+		authURL, err := provider.AuthCodeURL(ctx, "state", nonce, challenge)
+		require.NoError(t, err)
+
+		resp, err := noRedirects.Get(authURL)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusFound, resp.StatusCode)
+
+		callback, err := url.Parse(resp.Header.Get("Location"))
+		require.NoError(t, err)
+		assert.Equal(t, "state", callback.Query().Get("state"))
+		return callback.Query().Get("code")
+	}
+
+	t.Run("authorization code with PKCE", func(t *testing.T) {
+		verifier, challenge, err := auth.NewPKCE()
+		require.NoError(t, err)
+
+		idToken, err := provider.Exchange(ctx, authorize(t, "nonce", challenge), verifier)
+		require.NoError(t, err)
+
+		claims, err := provider.VerifyIDToken(ctx, idToken, "nonce")
+		require.NoError(t, err)
+		assert.Equal(t, "user-1", claims.Subject)
+	})
+
+	t.Run("rejects wrong code verifier", func(t *testing.T) {
+		_, challenge, err := auth.NewPKCE()
+		require.NoError(t, err)
+		otherVerifier, _, err := auth.NewPKCE()
+		require.NoError(t, err)
+
+		_, err = provider.Exchange(ctx, authorize(t, "nonce", challenge), otherVerifier)
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects nonce mismatch", func(t *testing.T) {
+		idToken, err := idp.IDToken("user-1", "other")
+		require.NoError(t, err)
+
+		_, err = provider.VerifyIDToken(ctx, idToken, "nonce")
+		assert.ErrorIs(t, err, auth.ErrOIDCNonceMismatch)
+	})
+
+	t.Run("rejects tokens for another client", func(t *testing.T) {
+		other := auth.NewOIDCProvider(auth.OIDCConfig{Issuer: idp.Issuer(), ClientID: "other"}, nil)
+		idToken, err := idp.IDToken("user-1", "nonce")
+		require.NoError(t, err)
+
+		_, err = other.VerifyIDToken(ctx, idToken, "nonce")
+		assert.Error(t, err)
+	})
+
+	t.Run("limits JWKS refetches for unknown keys", func(t *testing.T) {
+		idToken, err := idp.IDToken("user-1", "nonce")
+		require.NoError(t, err)
+		_, err = provider.VerifyIDToken(ctx, idToken, "nonce")
+		require.NoError(t, err)
+		requests := idp.JWKSRequests()
+
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, auth.IDTokenClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    idp.Issuer(),
+				Subject:   "user-1",
+				Audience:  jwt.ClaimStrings{"shortener"},
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			},
+			Nonce: "nonce",
+		})
+		token.Header["kid"] = "unknown"
+		forged, err := token.SignedString(key)
+		require.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			_, err = provider.VerifyIDToken(ctx, forged, "nonce")
+			assert.ErrorIs(t, err, auth.ErrUnknownKey)
+		}
+		assert.Equal(t, requests, idp.JWKSRequests())
+	})
+
+	t.Run("derives stable user IDs", func(t *testing.T) {
+		assert.Equal(t, provider.UserID("user-1"), provider.UserID("user-1"))
+		assert.NotEqual(t, provider.UserID("user-1"), provider.UserID("user-2"))
+	})
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Ограничения на пароль. bcrypt учитывает только первые 72 байта
+const (
+	MinPasswordLength = 8
+	MaxPasswordLength = 72
+)
+
+// ErrInvalidPassword пароль не подходит по длине
+var ErrInvalidPassword = errors.New("password must be 8-72 bytes long")
+
+// Хэш, с которым сравнивается пароль несуществующего пользователя,
+// чтобы по времени ответа нельзя было узнать, зарегистрирован ли логин
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+// HashPassword вычисляет bcrypt-хэш пароля
+func HashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength || len(password) > MaxPasswordLength {
+		return "", ErrInvalidPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword сравнивает пароль с хэшем. Пустой хэш означает неизвестного
+// пользователя: сравнение всё равно выполняется и заканчивается неудачей
+func CheckPassword(hash, password string) bool {
+	if hash == "" {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
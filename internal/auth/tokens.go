@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TokenCookieName имя куки с токеном пользователя
+const TokenCookieName = "token"
+
+// Политики обработки токенов с истёкшим сроком действия
+const (
+	// ExpiredReissue в пределах grace-периода выдаёт новый токен тому же пользователю,
+	// после него пользователь получает новую анонимную личность
+	ExpiredReissue = "reissue"
+	// ExpiredReject отклоняет любой токен с истёкшим сроком действия
+	ExpiredReject = "reject"
+)
+
+// Claims данные пользователя в токене. Anonymous отмечает личность, выданную
+// без входа в аккаунт: только её ссылки можно забрать в аккаунт
+type Claims struct {
+	jwt.RegisteredClaims
+	UserID    string `json:"user_id"`
+	Anonymous bool   `json:"anonymous,omitempty"`
+}
+
+// TokenManager выдаёт токены пользователей и проверяет их по единым для всех маршрутов правилам
+type TokenManager struct {
+	keys          *KeySet
+	lifetime      time.Duration
+	renewBefore   time.Duration
+	expiredPolicy string
+	grace         time.Duration
+	now           func() time.Time
+}
+
+// NewTokenManager создаёт менеджер токенов. Токены живут lifetime и продлеваются,
+// если до истечения осталось меньше renewBefore
+func NewTokenManager(keys *KeySet, lifetime, renewBefore time.Duration, expiredPolicy string, grace time.Duration) (*TokenManager, error) {
+	if lifetime <= 0 {
+		return nil, errors.New("token lifetime must be positive")
+	}
+	if renewBefore < 0 || renewBefore >= lifetime {
+		return nil, fmt.Errorf("token renewal window %s must be shorter than lifetime %s", renewBefore, lifetime)
+	}
+	switch expiredPolicy {
+	case ExpiredReissue, ExpiredReject:
+	default:
+		return nil, fmt.Errorf("unknown expired token policy %q", expiredPolicy)
+	}
+
+	return &TokenManager{
+		keys:          keys,
+		lifetime:      lifetime,
+		renewBefore:   renewBefore,
+		expiredPolicy: expiredPolicy,
+		grace:         grace,
+		now:           time.Now,
+	}, nil
+}
+
+// Keys возвращает ключи, которыми подписываются токены
+func (tm *TokenManager) Keys() *KeySet {
+	return tm.keys
+}
+
+// RejectsExpired сообщает, что токены с истёкшим сроком не восстанавливаются
+func (tm *TokenManager) RejectsExpired() bool {
+	return tm.expiredPolicy == ExpiredReject
+}
+
+// Issue выдаёт пользователю новый токен. Возвращает также время,
+// до которого клиенту стоит хранить куку с токеном
+func (tm *TokenManager) Issue(userID string, anonymous bool) (string, time.Time, error) {
+	now := tm.now()
+	expiresAt := now.Add(tm.lifetime)
+	tokenString, err := tm.keys.Sign(&Claims{
+		UserID:    userID,
+		Anonymous: anonymous,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	if tm.expiredPolicy == ExpiredReissue {
+		// Кука переживает токен, чтобы его можно было восстановить в grace-период
+		expiresAt = expiresAt.Add(tm.grace)
+	}
+	return tokenString, expiresAt, nil
+}
+
+// Authenticate проверяет токен и возвращает его данные. renew означает,
+// что клиенту нужно выдать новый токен: срок текущего скоро истечёт или уже истёк
+// в пределах grace-периода. Для неустранимо истёкшего токена возвращается jwt.ErrTokenExpired
+func (tm *TokenManager) Authenticate(tokenString string) (claims *Claims, renew bool, err error) {
+	claims = &Claims{}
+	_, err = tm.keys.Parse(tokenString, claims,
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(tm.now),
+	)
+
+	switch {
+	case err == nil:
+		renew = claims.ExpiresAt.Sub(tm.now()) < tm.renewBefore
+	case errors.Is(err, jwt.ErrTokenExpired) && claims.ExpiresAt != nil:
+		// Подпись уже проверена: claims проверяются после неё
+		if tm.expiredPolicy == ExpiredReject || tm.now().Sub(claims.ExpiresAt.Time) > tm.grace {
+			return nil, false, err
+		}
+		renew = true
+	default:
+		return nil, false, err
+	}
+
+	// Токены пользователей выдаются без aud: токен с aud подписан для другой цели
+	if len(claims.Audience) > 0 {
+		return nil, false, errors.New("token is not a user token")
+	}
+	if claims.UserID == "" {
+		return nil, false, errors.New("token has no user ID")
+	}
+	return claims, renew, nil
+}
+
+// SetCookie выдаёт пользователю новый токен и устанавливает его в куки
+func (tm *TokenManager) SetCookie(w http.ResponseWriter, userID string, anonymous bool) error {
+	tokenString, expiresAt, err := tm.Issue(userID, anonymous)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     TokenCookieName,
+		Value:    tokenString,
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Path:     "/",
+	})
+	return nil
+}
+
+// ClearCookie удаляет куку с токеном, следующий запрос получит новую анонимную личность
+func (tm *TokenManager) ClearCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     TokenCookieName,
+		Value:    "",
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+		HttpOnly: true,
+		Path:     "/",
+	})
+}
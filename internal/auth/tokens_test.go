@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenManager(t *testing.T) {
+	key, err := NewHMACKey("k", []byte(strings.Repeat("k", 32)))
+	require.NoError(t, err)
+	keys, err := NewKeySet("k", key)
+	require.NoError(t, err)
+
+	newManager := func(t *testing.T, policy string) (*TokenManager, *time.Time) {
+		tm, err := NewTokenManager(keys, 10*time.Hour, 2*time.Hour, policy, time.Hour)
+		require.NoError(t, err)
+		now := time.Now()
+		tm.now = func() time.Time { return now }
+		return tm, &now
+	}
+
+	t.Run("fresh token is not renewed", func(t *testing.T) {
+		tm, _ := newManager(t, ExpiredReissue)
+		tokenString, _, err := tm.Issue("user", true)
+		require.NoError(t, err)
+
+		claims, renew, err := tm.Authenticate(tokenString)
+		require.NoError(t, err)
+		assert.Equal(t, "user", claims.UserID)
+		assert.False(t, renew)
+	})
+
+	t.Run("token close to expiry is renewed", func(t *testing.T) {
+		tm, now := newManager(t, ExpiredReissue)
+		tokenString, _, err := tm.Issue("user", true)
+		require.NoError(t, err)
+
+		*now = now.Add(9 * time.Hour)
+		claims, renew, err := tm.Authenticate(tokenString)
+		require.NoError(t, err)
+		assert.Equal(t, "user", claims.UserID)
+		assert.True(t, renew)
+	})
+
+	t.Run("expired token is reissued within grace period", func(t *testing.T) {
+		tm, now := newManager(t, ExpiredReissue)
+		tokenString, cookieExpiresAt, err := tm.Issue("user", true)
+		require.NoError(t, err)
+		assert.Equal(t, now.Add(11*time.Hour).Unix(), cookieExpiresAt.Unix())
+
+		*now = now.Add(10*time.Hour + 30*time.Minute)
+		claims, renew, err := tm.Authenticate(tokenString)
+		require.NoError(t, err)
+		assert.Equal(t, "user", claims.UserID)
+		assert.True(t, renew)
+
+		*now = now.Add(time.Hour)
+		_, _, err = tm.Authenticate(tokenString)
+		assert.ErrorIs(t, err, jwt.ErrTokenExpired)
+	})
+
+	t.Run("expired token is rejected by reject policy", func(t *testing.T) {
+		tm, now := newManager(t, ExpiredReject)
+		tokenString, _, err := tm.Issue("user", true)
+		require.NoError(t, err)
+
+		*now = now.Add(10*time.Hour + time.Minute)
+		_, _, err = tm.Authenticate(tokenString)
+		assert.ErrorIs(t, err, jwt.ErrTokenExpired)
+		assert.True(t, tm.RejectsExpired())
+	})
+
+	t.Run("token without expiry is rejected", func(t *testing.T) {
+		tm, _ := newManager(t, ExpiredReissue)
+		tokenString, err := keys.Sign(&Claims{UserID: "user"})
+		require.NoError(t, err)
+
+		_, _, err = tm.Authenticate(tokenString)
+		assert.Error(t, err)
+	})
+
+	t.Run("token with audience is rejected", func(t *testing.T) {
+		tm, now := newManager(t, ExpiredReissue)
+		tokenString, err := keys.Sign(&Claims{
+			UserID: "user",
+			RegisteredClaims: jwt.RegisteredClaims{
+				Audience:  jwt.ClaimStrings{"oidc_state"},
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			},
+		})
+		require.NoError(t, err)
+
+		_, _, err = tm.Authenticate(tokenString)
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid settings", func(t *testing.T) {
+		_, err := NewTokenManager(keys, time.Hour, 2*time.Hour, ExpiredReissue, 0)
+		assert.Error(t, err)
+		_, err = NewTokenManager(keys, time.Hour, 0, "ignore", 0)
+		assert.Error(t, err)
+	})
+}
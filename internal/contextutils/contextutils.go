@@ -1,13 +1,19 @@
 package contextutils
 
-import "context"
+import (
+	"context"
+	"log/slog"
+)
 
 // Contextual key for userID
 type contextKey struct {
 	name string
 }
 
-var userIDContextKey = &contextKey{"userID"}
+var (
+	userIDContextKey    = &contextKey{"userID"}
+	anonymousContextKey = &contextKey{"anonymous"}
+)
 
 // GetUserID retrieves the userID from the context
 func GetUserID(ctx context.Context) (string, bool) {
@@ -15,6 +21,22 @@ func GetUserID(ctx context.Context) (string, bool) {
 	return userID, ok
 }
 
+// WithUserID stores the userID in the context and adds it to the request-scoped logger
 func WithUserID(ctx context.Context, userID string) context.Context {
-	return context.WithValue(ctx, userIDContextKey, userID)
+	ctx = context.WithValue(ctx, userIDContextKey, userID)
+	if l, ok := ctx.Value(loggerContextKey).(*slog.Logger); ok {
+		ctx = WithLogger(ctx, l.With("user_id", userID))
+	}
+	return ctx
+}
+
+// IsAnonymous reports whether the user in the context has not signed in to an account
+func IsAnonymous(ctx context.Context) bool {
+	anonymous, _ := ctx.Value(anonymousContextKey).(bool)
+	return anonymous
+}
+
+// WithAnonymous marks the user in the context as anonymous
+func WithAnonymous(ctx context.Context) context.Context {
+	return context.WithValue(ctx, anonymousContextKey, true)
 }
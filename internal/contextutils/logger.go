@@ -0,0 +1,42 @@
+package contextutils
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/learies/go-url-shortener/internal/logger"
+)
+
+var (
+	requestIDContextKey = &contextKey{"requestID"}
+	loggerContextKey    = &contextKey{"logger"}
+)
+
+// GetRequestID retrieves the request ID from the context
+func GetRequestID(ctx context.Context) (string, bool) {
+	requestID, ok := ctx.Value(requestIDContextKey).(string)
+	return requestID, ok
+}
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey, requestID)
+}
+
+// Logger returns the request-scoped logger, or the global logger outside of a request.
+// Once the router has matched a route, its pattern is added to the logger
+func Logger(ctx context.Context) *slog.Logger {
+	l, ok := ctx.Value(loggerContextKey).(*slog.Logger)
+	if !ok {
+		return logger.Log
+	}
+	if rctx := chi.RouteContext(ctx); rctx != nil && rctx.RoutePattern() != "" {
+		return l.With("route", rctx.RoutePattern())
+	}
+	return l
+}
+
+func WithLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey, l)
+}
@@ -1,38 +1,38 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
 )
 
+// Connect подключается к базе данных и применяет к ней недостающие миграции
 func Connect(dsn string) (*sql.DB, error) {
-	db, err := sql.Open("pgx", dsn)
+	db, err := Open(dsn)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := initialize(db); err != nil {
+	if _, err := MigrateUp(context.Background(), db); err != nil {
+		db.Close()
 		return nil, err
 	}
 
 	return db, nil
 }
 
-func initialize(db *sql.DB) error {
-	query := `
-	CREATE TABLE IF NOT EXISTS urls (
-		id UUID PRIMARY KEY,
-		short_url VARCHAR(8) NOT NULL UNIQUE,
-		original_url TEXT NOT NULL UNIQUE,
-		user_id UUID NOT NULL,
-		is_deleted BOOLEAN NOT NULL DEFAULT FALSE
-	);`
-
-	_, err := db.Exec(query)
+// Open подключается к базе данных без применения миграций
+func Open(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("pgx", dsn)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
 }
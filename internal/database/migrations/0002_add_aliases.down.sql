@@ -0,0 +1,5 @@
+DELETE FROM urls WHERE is_alias;
+DROP INDEX IF EXISTS urls_original_url_idx;
+ALTER TABLE urls ADD CONSTRAINT urls_original_url_key UNIQUE (original_url);
+ALTER TABLE urls DROP COLUMN IF EXISTS is_alias;
+ALTER TABLE urls ALTER COLUMN short_url TYPE VARCHAR(8);
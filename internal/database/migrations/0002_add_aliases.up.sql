@@ -0,0 +1,6 @@
+-- Оригинальный URL уникален только среди сгенерированных коротких URL:
+-- пользовательский алиас может указывать на уже сокращённый URL
+ALTER TABLE urls ALTER COLUMN short_url TYPE VARCHAR(64);
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS is_alias BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE urls DROP CONSTRAINT IF EXISTS urls_original_url_key;
+CREATE UNIQUE INDEX IF NOT EXISTS urls_original_url_idx ON urls (original_url) WHERE NOT is_alias;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS url_history (
+	id UUID PRIMARY KEY,
+	short_url VARCHAR(64) NOT NULL REFERENCES urls (short_url) ON DELETE CASCADE,
+	old_url TEXT NOT NULL,
+	new_url TEXT NOT NULL,
+	changed_by UUID NOT NULL,
+	changed_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS url_history_short_url_idx ON url_history (short_url, changed_at);
@@ -0,0 +1,5 @@
+DELETE FROM urls u USING urls o
+WHERE u.original_url = o.original_url AND u.id <> o.id
+  AND NOT u.is_alias AND NOT o.is_alias AND u.is_deleted;
+DROP INDEX IF EXISTS urls_original_url_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS urls_original_url_idx ON urls (original_url) WHERE NOT is_alias;
@@ -0,0 +1,3 @@
+-- Удалённый пользователем URL не мешает сократить тот же оригинальный URL заново
+DROP INDEX IF EXISTS urls_original_url_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS urls_original_url_idx ON urls (original_url) WHERE NOT is_alias AND NOT is_deleted;
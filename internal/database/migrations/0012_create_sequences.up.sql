@@ -0,0 +1,5 @@
+-- Постоянные счётчики, например позиция генератора коротких URL
+CREATE TABLE IF NOT EXISTS sequences (
+	name VARCHAR(64) PRIMARY KEY,
+	value BIGINT NOT NULL
+);
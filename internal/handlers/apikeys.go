@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/learies/go-url-shortener/internal/auth"
+	"github.com/learies/go-url-shortener/internal/contextutils"
+	"github.com/learies/go-url-shortener/internal/models"
+	"github.com/learies/go-url-shortener/internal/store"
+	"github.com/learies/go-url-shortener/internal/store/storeerrors"
+)
+
+// Максимальная длина имени ключа доступа
+const maxAPIKeyNameLength = 100
+
+// CreateAPIKeyHandler создаёт ключ доступа для текущего пользователя.
+// Сам ключ возвращается только в этом ответе
+func CreateAPIKeyHandler(store store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+		defer cancel()
+
+		userID, ok := contextutils.GetUserID(ctx)
+		if !ok {
+			http.Error(w, "UserID not found in context", http.StatusUnauthorized)
+			return
+		}
+
+		var request models.APIKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		defer r.Body.Close()
+
+		request.Name = strings.TrimSpace(request.Name)
+		if request.Name == "" || len(request.Name) > maxAPIKeyNameLength {
+			http.Error(w, "Key name must be 1-100 characters long", http.StatusBadRequest)
+			return
+		}
+
+		key, prefix, hash, err := auth.GenerateAPIKey()
+		if err != nil {
+			http.Error(w, "Failed to generate API key", http.StatusInternalServerError)
+			return
+		}
+
+		apiKey := models.APIKey{
+			ID:        uuid.New().String(),
+			UserID:    userID,
+			Name:      request.Name,
+			Prefix:    prefix,
+			KeyHash:   hash,
+			CreatedAt: time.Now().UTC(),
+		}
+		if err := store.CreateAPIKey(ctx, apiKey); err != nil {
+			contextutils.Logger(ctx).Error("Failed to store API key", "error", err)
+			http.Error(w, "Failed to store API key", http.StatusInternalServerError)
+			return
+		}
+
+		response := apiKeyResponse(apiKey)
+		response.Key = key
+
+		result, err := json.Marshal(response)
+		if err != nil {
+			http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(result)
+	}
+}
+
+// GetAPIKeysHandler возвращает действующие ключи доступа текущего пользователя
+func GetAPIKeysHandler(store store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+		defer cancel()
+
+		userID, ok := contextutils.GetUserID(ctx)
+		if !ok {
+			http.Error(w, "UserID not found in context", http.StatusUnauthorized)
+			return
+		}
+
+		apiKeys, err := store.GetUserAPIKeys(ctx, userID)
+		if err != nil {
+			contextutils.Logger(ctx).Error("Failed to get API keys", "error", err)
+			http.Error(w, "Failed to get API keys", http.StatusInternalServerError)
+			return
+		}
+
+		response := make([]models.APIKeyResponse, len(apiKeys))
+		for i, apiKey := range apiKeys {
+			response[i] = apiKeyResponse(apiKey)
+		}
+
+		result, err := json.Marshal(response)
+		if err != nil {
+			http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(result)
+	}
+}
+
+// RevokeAPIKeyHandler отзывает ключ доступа текущего пользователя
+func RevokeAPIKeyHandler(store store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+		defer cancel()
+
+		userID, ok := contextutils.GetUserID(ctx)
+		if !ok {
+			http.Error(w, "UserID not found in context", http.StatusUnauthorized)
+			return
+		}
+
+		keyID := chi.URLParam(r, "id")
+		if _, err := uuid.Parse(keyID); err != nil {
+			http.Error(w, "API key not found", http.StatusNotFound)
+			return
+		}
+
+		err := store.RevokeAPIKey(ctx, userID, keyID)
+		if errors.Is(err, storeerrors.ErrAPIKeyNotFound) {
+			http.Error(w, "API key not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			contextutils.Logger(ctx).Error("Failed to revoke API key", "error", err)
+			http.Error(w, "Failed to revoke API key", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func apiKeyResponse(apiKey models.APIKey) models.APIKeyResponse {
+	return models.APIKeyResponse{
+		ID:        apiKey.ID,
+		Name:      apiKey.Name,
+		Prefix:    apiKey.Prefix,
+		CreatedAt: apiKey.CreatedAt,
+	}
+}
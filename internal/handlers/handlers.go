@@ -3,57 +3,108 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"math"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/learies/go-url-shortener/config"
+	"github.com/learies/go-url-shortener/internal/analytics"
+	"github.com/learies/go-url-shortener/internal/auth"
 	"github.com/learies/go-url-shortener/internal/contextutils"
-	"github.com/learies/go-url-shortener/internal/logger"
+	"github.com/learies/go-url-shortener/internal/metrics"
 	"github.com/learies/go-url-shortener/internal/models"
 	"github.com/learies/go-url-shortener/internal/shortener"
 	"github.com/learies/go-url-shortener/internal/store"
+	"github.com/learies/go-url-shortener/internal/store/storeerrors"
 	"github.com/learies/go-url-shortener/internal/worker"
 )
 
-func PostAPIHandler(store store.Store, cfg config.Config, urlShortener *shortener.URLShortener) http.HandlerFunc {
+// isValidURL проверяет, что URL абсолютный и использует схему http или https
+func isValidURL(url string) bool {
+	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
+}
+
+// conflictShortURL возвращает короткий URL, под которым оригинальный URL уже сохранён.
+// Если хранилище его не сообщило, используется вычисленный shortURL
+func conflictShortURL(err error, shortURL string) string {
+	var conflict *storeerrors.ErrConflict
+	if errors.As(err, &conflict) {
+		return conflict.ShortURL
+	}
+	return shortURL
+}
+
+// sameRedirectType проверяет, что сохранённый короткий URL перенаправляет запрошенным
+// кодом requested. Без запрошенного кода подходит любая ссылка
+func sameRedirectType(ctx context.Context, store store.Store, cfg config.Config, shortURL string, requested int) bool {
+	if requested == 0 {
+		return true
+	}
+	s, exists := store.Get(ctx, shortURL)
+	if !exists {
+		return true
+	}
+	return s.RedirectType == requested || s.RedirectType == 0 && cfg.RedirectCode == requested
+}
+
+// expirationTime вычисляет момент истечения срока действия URL
+// по абсолютному времени expires_at или по ttl_seconds
+func expirationTime(expiresAt *time.Time, ttlSeconds int64) (*time.Time, error) {
+	switch {
+	case expiresAt != nil && ttlSeconds != 0:
+		return nil, errors.New("only one of expires_at and ttl_seconds may be set")
+	case ttlSeconds < 0 || ttlSeconds > math.MaxInt64/int64(time.Second):
+		return nil, errors.New("ttl_seconds is out of range")
+	case ttlSeconds > 0:
+		t := time.Now().Add(time.Duration(ttlSeconds) * time.Second)
+		return &t, nil
+	case expiresAt != nil && !expiresAt.After(time.Now()):
+		return nil, errors.New("expires_at must be in the future")
+	}
+	return expiresAt, nil
+}
+
+func PostAPIHandler(store store.Store, cfg config.Config, urlShortener shortener.Generator) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
 		defer cancel()
 
-		if r.Body == nil {
-			http.Error(w, "Empty request body", http.StatusBadRequest)
-			return
-		}
-
-		body, err := io.ReadAll(r.Body)
-		if err != nil {
-			http.Error(w, "Unable to read the request body", http.StatusInternalServerError)
+		body, ok := readBody(w, r, cfg)
+		if !ok {
 			return
 		}
 
 		var request models.Request
-		if err = json.Unmarshal(body, &request); err != nil {
+		if err := json.Unmarshal(body, &request); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
 		originalURL := string(request.URL)
-		if !strings.HasPrefix(originalURL, "http://") && !strings.HasPrefix(originalURL, "https://") {
+		if !isValidURL(originalURL) {
 			http.Error(w, "Invalid URL format", http.StatusBadRequest)
 			return
 		}
 
-		shortURL := urlShortener.GenerateShortURL(originalURL)
-
-		var response models.Response
-		response.Result = cfg.BaseURL + "/" + shortURL
+		if request.Alias != "" {
+			if err := shortener.ValidateAlias(request.Alias); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
 
-		result, err := json.Marshal(response)
+		expiresAt, err := expirationTime(request.ExpiresAt, request.TTLSeconds)
 		if err != nil {
-			http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if request.RedirectType != 0 && !models.ValidRedirectType(request.RedirectType) {
+			http.Error(w, "redirect_type must be one of 301, 302, 307 or 308", http.StatusBadRequest)
 			return
 		}
 
@@ -64,22 +115,70 @@ func PostAPIHandler(store store.Store, cfg config.Config, urlShortener *shortene
 			return
 		}
 
-		err = store.Set(ctx, shortURL, originalURL, userID)
+		// Алиас всегда создаёт новую ссылку, сгенерированный код — только для нового URL
+		var generated []string
+		if request.Alias == "" {
+			generated = append(generated, originalURL)
+		}
+		if !checkLinkQuota(ctx, w, store, cfg, userID, 1, generated...) {
+			return
+		}
+
+		url := models.Storage{
+			OriginalURL:  originalURL,
+			UserID:       userID,
+			ExpiresAt:    expiresAt,
+			RedirectType: request.RedirectType,
+		}
+
+		var shortURL string
+		if request.Alias != "" {
+			shortURL = request.Alias
+			url.ShortURL = shortURL
+			err = store.SetAlias(ctx, url)
+		} else {
+			shortURL, err = shortener.Shorten(urlShortener, originalURL, func(shortURL string) error {
+				url.ShortURL = shortURL
+				return store.Set(ctx, url)
+			})
+		}
+
+		status := http.StatusCreated
+		switch {
+		case err == nil:
+		case errors.Is(err, storeerrors.ErrAliasTaken):
+			http.Error(w, "Alias is already taken", http.StatusConflict)
+			return
+		case errors.Is(err, storeerrors.ErrURLExists):
+			status = http.StatusConflict
+			shortURL = conflictShortURL(err, shortURL)
+			// Ссылка, ведущая другим кодом, не то, что просил клиент
+			if !sameRedirectType(ctx, store, cfg, shortURL, request.RedirectType) {
+				http.Error(w, "URL is already shortened with a different redirect_type", http.StatusConflict)
+				return
+			}
+		default:
+			contextutils.Logger(ctx).Error(fmt.Sprintf("Failed to store URL: %v", err))
+			http.Error(w, "Failed to store URL", http.StatusInternalServerError)
+			return
+		}
+
+		var response models.Response
+		response.Result = cfg.BaseURL + "/" + shortURL
+
+		result, err := json.Marshal(response)
 		if err != nil {
-			logger.Log.Error(fmt.Sprintf("Failed to store URL: %v", err))
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusConflict)
-			w.Write(result)
+			http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusCreated)
+		w.WriteHeader(status)
 		w.Write(result)
 	}
 }
 
-func PostAPIBatchHandler(store store.Store, cfg config.Config, urlShortener *shortener.URLShortener) http.HandlerFunc {
+func PostAPIBatchHandler(store store.Store, cfg config.Config, urlShortener shortener.Generator) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
 		defer cancel()
@@ -91,40 +190,91 @@ func PostAPIBatchHandler(store store.Store, cfg config.Config, urlShortener *sho
 			return
 		}
 
-		if r.Body == nil {
-			http.Error(w, "Empty request body", http.StatusBadRequest)
+		body, ok := readBody(w, r, cfg)
+		if !ok {
 			return
 		}
 
-		body, err := io.ReadAll(r.Body)
-		if err != nil {
-			http.Error(w, "Unable to read the request body", http.StatusInternalServerError)
+		var requests []models.BatchURLRequest
+		if err := json.Unmarshal(body, &requests); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		var requests []models.BatchURLRequest
-		if err = json.Unmarshal(body, &requests); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		if len(requests) == 0 {
+			http.Error(w, "Empty batch", http.StatusBadRequest)
+			return
+		}
+		if !checkBatchSize(w, cfg, len(requests)) {
 			return
 		}
 
-		var responses []models.BatchURLResponse
-		var batchWrites []models.BatchURLWrite
-		for _, request := range requests {
-			shortURL := urlShortener.GenerateShortURL(request.OriginalURL)
-			responses = append(responses, models.BatchURLResponse{
-				CorrelationID: request.CorrelationID,
-				ShortURL:      cfg.BaseURL + "/" + shortURL,
-			})
-			batchWrites = append(batchWrites, models.BatchURLWrite{
+		// Режим all-or-nothing включается параметром mode=atomic
+		var atomic bool
+		switch r.URL.Query().Get("mode") {
+		case "", "best_effort":
+		case "atomic":
+			atomic = true
+		default:
+			http.Error(w, "Unknown batch mode", http.StatusBadRequest)
+			return
+		}
+
+		responses := make([]models.BatchURLResponse, len(requests))
+		writes := make([]models.BatchURLWrite, len(requests))
+		var pending []int
+		for i, request := range requests {
+			responses[i].CorrelationID = request.CorrelationID
+
+			if !isValidURL(request.OriginalURL) {
+				responses[i].Status = models.BatchStatusInvalid
+				responses[i].Error = "Invalid URL format"
+				continue
+			}
+
+			expiresAt, err := expirationTime(request.ExpiresAt, request.TTLSeconds)
+			if err != nil {
+				responses[i].Status = models.BatchStatusInvalid
+				responses[i].Error = err.Error()
+				continue
+			}
+
+			writes[i] = models.BatchURLWrite{
 				CorrelationID: request.CorrelationID,
-				ShortURL:      shortURL,
 				OriginalURL:   request.OriginalURL,
 				UserID:        userID,
-			})
+				ExpiresAt:     expiresAt,
+			}
+			pending = append(pending, i)
 		}
 
-		store.SetBatch(ctx, batchWrites)
+		// Атомарный пакет с некорректными элементами ничего не создаёт, квота не нужна
+		if len(pending) > 0 && (!atomic || len(pending) == len(requests)) {
+			originalURLs := make([]string, len(pending))
+			for j, i := range pending {
+				originalURLs[j] = writes[i].OriginalURL
+			}
+			if !checkLinkQuota(ctx, w, store, cfg, userID, len(pending), originalURLs...) {
+				return
+			}
+		}
+
+		var err error
+		switch {
+		case atomic && len(pending) < len(requests):
+			for _, i := range pending {
+				responses[i].Status = models.BatchStatusAborted
+			}
+		case atomic:
+			err = saveBatchAtomic(ctx, store, urlShortener, cfg.BaseURL, writes, responses)
+		default:
+			err = saveBatch(ctx, store, urlShortener, cfg.BaseURL, writes, pending, responses)
+		}
+		if err != nil {
+			contextutils.Logger(ctx).Error("Failed to store URL batch", "error", err)
+			http.Error(w, "Failed to store URLs", http.StatusInternalServerError)
+			return
+		}
 
 		result, err := json.Marshal(responses)
 		if err != nil {
@@ -133,25 +283,144 @@ func PostAPIBatchHandler(store store.Store, cfg config.Config, urlShortener *sho
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusCreated)
+		w.WriteHeader(batchStatusCode(responses))
 		w.Write(result)
 	}
 }
 
-func PostHandler(store store.Store, cfg config.Config, urlShortener *shortener.URLShortener) http.HandlerFunc {
+// saveBatch сохраняет элементы пакета с индексами pending независимо друг от друга.
+// Элементам, чей короткий URL оказался занят, подбирается новый код
+func saveBatch(ctx context.Context, store store.Store, urlShortener shortener.Generator, baseURL string,
+	writes []models.BatchURLWrite, pending []int, responses []models.BatchURLResponse) error {
+	for attempt := 0; attempt < shortener.MaxAttempts && len(pending) > 0; attempt++ {
+		var batch []models.BatchURLWrite
+		var indexes, retry []int
+		for _, i := range pending {
+			writes[i].ShortURL = urlShortener.GenerateShortURL(writes[i].OriginalURL, attempt)
+			if shortener.IsReserved(writes[i].ShortURL) {
+				retry = append(retry, i)
+				continue
+			}
+			batch = append(batch, writes[i])
+			indexes = append(indexes, i)
+		}
+
+		results, err := store.SetBatch(ctx, batch, false)
+		if err != nil {
+			return err
+		}
+
+		for j, result := range results {
+			i := indexes[j]
+			if result.Status == models.BatchStatusTaken {
+				retry = append(retry, i)
+				continue
+			}
+			responses[i].Status = result.Status
+			responses[i].ShortURL = baseURL + "/" + result.ShortURL
+		}
+		pending = retry
+	}
+
+	for _, i := range pending {
+		responses[i].Status = models.BatchStatusFailed
+		responses[i].Error = shortener.ErrAttemptsExhausted.Error()
+	}
+	return nil
+}
+
+// saveBatchAtomic сохраняет пакет целиком или не сохраняет ничего.
+// Если пакет отменён только из-за занятых коротких URL, он повторяется с новыми кодами
+func saveBatchAtomic(ctx context.Context, store store.Store, urlShortener shortener.Generator, baseURL string,
+	writes []models.BatchURLWrite, responses []models.BatchURLResponse) error {
+	var results []models.BatchURLResult
+	for attempt := 0; attempt < shortener.MaxAttempts; attempt++ {
+		reserved := false
+		for i := range writes {
+			writes[i].ShortURL = urlShortener.GenerateShortURL(writes[i].OriginalURL, attempt)
+			reserved = reserved || shortener.IsReserved(writes[i].ShortURL)
+		}
+		if reserved {
+			continue
+		}
+
+		var err error
+		results, err = store.SetBatch(ctx, writes, true)
+		if err == nil {
+			for i, result := range results {
+				responses[i].Status = result.Status
+				responses[i].ShortURL = baseURL + "/" + result.ShortURL
+			}
+			return nil
+		}
+		if !errors.Is(err, storeerrors.ErrBatchRolledBack) {
+			return err
+		}
+
+		if hasBatchStatus(results, models.BatchStatusExists) {
+			for i, result := range results {
+				responses[i].Status = models.BatchStatusAborted
+				if result.Status == models.BatchStatusExists {
+					responses[i].Status = result.Status
+					responses[i].ShortURL = baseURL + "/" + result.ShortURL
+				}
+			}
+			return nil
+		}
+	}
+
+	for i := range responses {
+		responses[i].Status = models.BatchStatusAborted
+		if i < len(results) && results[i].Status == models.BatchStatusTaken {
+			responses[i].Status = models.BatchStatusFailed
+			responses[i].Error = shortener.ErrAttemptsExhausted.Error()
+		}
+	}
+	return nil
+}
+
+func hasBatchStatus(results []models.BatchURLResult, status string) bool {
+	for _, result := range results {
+		if result.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+// batchStatusCode выбирает код ответа по результатам элементов пакета:
+// 201, если хоть что-то создано, 409, если URL уже были сокращены,
+// 500, если не удалось подобрать короткий URL, и 400 в остальных случаях
+func batchStatusCode(responses []models.BatchURLResponse) int {
+	statuses := make(map[string]int)
+	for _, response := range responses {
+		statuses[response.Status]++
+	}
+
+	switch {
+	case statuses[models.BatchStatusCreated] > 0:
+		return http.StatusCreated
+	case statuses[models.BatchStatusExists] > 0:
+		return http.StatusConflict
+	case statuses[models.BatchStatusFailed] > 0:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func PostHandler(store store.Store, cfg config.Config, urlShortener shortener.Generator) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
 		defer cancel()
 
-		body, err := io.ReadAll(r.Body)
-		if err != nil {
-			http.Error(w, "Unable to read the request body", http.StatusInternalServerError)
+		body, ok := readBody(w, r, cfg)
+		if !ok {
 			return
 		}
-		defer r.Body.Close()
 
 		originalURL := string(body)
-		if !strings.HasPrefix(originalURL, "http://") && !strings.HasPrefix(originalURL, "https://") {
+		if !isValidURL(originalURL) {
 			http.Error(w, "Invalid URL format", http.StatusBadRequest)
 			return
 		}
@@ -163,25 +432,37 @@ func PostHandler(store store.Store, cfg config.Config, urlShortener *shortener.U
 			return
 		}
 
-		shortURL := urlShortener.GenerateShortURL(originalURL)
-		shortenedURL := cfg.BaseURL + "/" + shortURL
+		if !checkLinkQuota(ctx, w, store, cfg, userID, 1, originalURL) {
+			return
+		}
 
-		err = store.Set(ctx, shortURL, originalURL, userID)
-		if err != nil {
-			logger.Log.Error(fmt.Sprintf("Failed to store URL: %v", err))
-			w.Header().Set("Content-Type", "text/plain")
-			w.WriteHeader(http.StatusConflict)
-			w.Write([]byte(shortenedURL))
+		shortURL, err := shortener.Shorten(urlShortener, originalURL, func(shortURL string) error {
+			return store.Set(ctx, models.Storage{ShortURL: shortURL, OriginalURL: originalURL, UserID: userID})
+		})
+
+		status := http.StatusCreated
+		switch {
+		case err == nil:
+		case errors.Is(err, storeerrors.ErrURLExists):
+			status = http.StatusConflict
+			shortURL = conflictShortURL(err, shortURL)
+		default:
+			contextutils.Logger(ctx).Error(fmt.Sprintf("Failed to store URL: %v", err))
+			http.Error(w, "Failed to store URL", http.StatusInternalServerError)
 			return
 		}
 
 		w.Header().Set("Content-Type", "text/plain")
-		w.WriteHeader(http.StatusCreated)
-		w.Write([]byte(shortenedURL))
+		w.WriteHeader(status)
+		w.Write([]byte(cfg.BaseURL + "/" + shortURL))
 	}
 }
 
-func GetHandler(store store.Store) http.HandlerFunc {
+// GetHandler перенаправляет по короткому URL кодом, выбранным для URL, или кодом
+// по умолчанию из конфигурации. Постоянные перенаправления разрешено кэшировать
+// не дольше cfg.RedirectCacheMaxAge и срока действия URL; временные не кэшируются,
+// чтобы каждый переход доходил до сервиса и учитывался в статистике
+func GetHandler(store store.Store, cfg config.Config, clicks *worker.ClickRecorder, m *metrics.Metrics) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
 		defer cancel()
@@ -190,17 +471,88 @@ func GetHandler(store store.Store) http.HandlerFunc {
 
 		s, exists := store.Get(ctx, shortURL)
 		if !exists {
+			m.ObserveRedirect(metrics.RedirectNotFound)
 			http.Error(w, "URL not found", http.StatusNotFound)
 			return
 		}
 
 		if s.DeletedFlag {
+			m.ObserveRedirect(metrics.RedirectDeleted)
 			http.Error(w, "URL is deleted", http.StatusGone)
 			return
 		}
 
+		if s.Expired(time.Now()) {
+			m.ObserveRedirect(metrics.RedirectExpired)
+			http.Error(w, "URL is expired", http.StatusGone)
+			return
+		}
+
+		m.ObserveRedirect(metrics.RedirectHit)
+		clicks.Record(analytics.NewClick(r, shortURL))
+
+		code := s.RedirectType
+		if code == 0 {
+			code = cfg.RedirectCode
+		}
+
+		w.Header().Set("Cache-Control", redirectCacheControl(code, s.ExpiresAt, cfg.RedirectCacheMaxAge))
 		w.Header().Set("Location", s.OriginalURL)
-		w.WriteHeader(http.StatusTemporaryRedirect)
+		w.WriteHeader(code)
+	}
+}
+
+// redirectCacheControl выбирает Cache-Control для перенаправления кодом code
+func redirectCacheControl(code int, expiresAt *time.Time, maxAge time.Duration) string {
+	if code != http.StatusMovedPermanently && code != http.StatusPermanentRedirect {
+		return "private, no-cache"
+	}
+	if expiresAt != nil {
+		maxAge = min(maxAge, time.Until(*expiresAt))
+	}
+	if maxAge <= 0 {
+		return "no-cache"
+	}
+	return fmt.Sprintf("public, max-age=%d", int64(maxAge/time.Second))
+}
+
+// GetURLStatsHandler возвращает статистику переходов по короткому URL его владельцу
+func GetURLStatsHandler(store store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+		defer cancel()
+
+		userID, ok := contextutils.GetUserID(ctx)
+		if !ok {
+			http.Error(w, "UserID not found in context", http.StatusUnauthorized)
+			return
+		}
+
+		shortURL := chi.URLParam(r, "short")
+
+		// Чужие URL не отличаем от несуществующих
+		s, exists := store.Get(ctx, shortURL)
+		if !exists || s.UserID != userID {
+			http.Error(w, "URL not found", http.StatusNotFound)
+			return
+		}
+
+		stats, err := store.GetClickStats(ctx, shortURL)
+		if err != nil {
+			contextutils.Logger(ctx).Error("Failed to get click stats", "error", err)
+			http.Error(w, "Failed to get click stats", http.StatusInternalServerError)
+			return
+		}
+
+		result, err := json.Marshal(stats)
+		if err != nil {
+			http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(result)
 	}
 }
 
@@ -250,7 +602,7 @@ func GetAPIUserURLsHandler(store store.Store, cfg config.Config) http.HandlerFun
 	}
 }
 
-func DeleteUserUrlsHandler(store store.Store) http.HandlerFunc {
+func DeleteUserUrlsHandler(deleter *worker.Deleter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
 		defer cancel()
@@ -267,11 +619,19 @@ func DeleteUserUrlsHandler(store store.Store) http.HandlerFunc {
 			return
 		}
 
+		userURLs := make([]models.UserURL, 0, len(shortURLs.ShortURLs))
 		for _, shortURL := range shortURLs.ShortURLs {
-			store.DeleteUserUrls(ctx, worker.GenerateShortURL(models.UserURL{
+			userURLs = append(userURLs, models.UserURL{
 				UserID:   userID,
 				ShortURL: shortURL,
-			}))
+			})
+		}
+
+		// Удаление выполняется в фоне, после ответа клиенту
+		if err := deleter.Enqueue(ctx, userURLs...); err != nil {
+			contextutils.Logger(ctx).Error("Failed to enqueue URLs for deletion", "error", err)
+			http.Error(w, "Failed to delete URLs", http.StatusServiceUnavailable)
+			return
 		}
 
 		w.WriteHeader(http.StatusAccepted)
@@ -283,7 +643,7 @@ func PingHandler(store store.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if err := store.Ping(); err != nil {
 			http.Error(w, "Store is not available", http.StatusInternalServerError)
-			logger.Log.Error("Store ping failed", "error", err)
+			contextutils.Logger(r.Context()).Error("Store ping failed", "error", err)
 			return
 		}
 
@@ -291,3 +651,18 @@ func PingHandler(store store.Store) http.HandlerFunc {
 		w.Write([]byte("Successfully connected to the store"))
 	}
 }
+
+// JWKSHandler отдаёт открытые ключи, которыми другие сервисы могут проверять наши токены
+func JWKSHandler(keys *auth.KeySet) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		result, err := json.Marshal(keys.JWKS())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(result)
+	}
+}
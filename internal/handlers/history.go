@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/learies/go-url-shortener/config"
+	"github.com/learies/go-url-shortener/internal/contextutils"
+	"github.com/learies/go-url-shortener/internal/models"
+	"github.com/learies/go-url-shortener/internal/store"
+	"github.com/learies/go-url-shortener/internal/store/storeerrors"
+)
+
+// UpdateURLHandler меняет оригинальный URL, на который ведёт короткий URL текущего пользователя.
+// Истёкший URL, как и при переходе, отвечает 410 Gone.
+// Браузеры, закэшировавшие постоянное перенаправление, увидят новый адрес не позже
+// чем через cfg.RedirectCacheMaxAge
+func UpdateURLHandler(store store.Store, cfg config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+		defer cancel()
+
+		userID, ok := contextutils.GetUserID(ctx)
+		if !ok {
+			http.Error(w, "UserID not found in context", http.StatusUnauthorized)
+			return
+		}
+
+		body, ok := readBody(w, r, cfg)
+		if !ok {
+			return
+		}
+
+		var request models.UpdateURLRequest
+		if err := json.Unmarshal(body, &request); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if !isValidURL(request.OriginalURL) {
+			http.Error(w, "Invalid URL format", http.StatusBadRequest)
+			return
+		}
+
+		shortURL := chi.URLParam(r, "short")
+
+		// Чужие URL не отличаем от несуществующих
+		s, exists := store.Get(ctx, shortURL)
+		if !exists || s.UserID != userID || s.DeletedFlag {
+			http.Error(w, "URL not found", http.StatusNotFound)
+			return
+		}
+		if s.Expired(time.Now()) {
+			http.Error(w, "URL is expired", http.StatusGone)
+			return
+		}
+
+		changeOriginalURL(ctx, w, store, cfg, s, request.OriginalURL)
+	}
+}
+
+// GetURLHistoryHandler возвращает историю изменений оригинального URL текущего пользователя
+func GetURLHistoryHandler(store store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+		defer cancel()
+
+		userID, ok := contextutils.GetUserID(ctx)
+		if !ok {
+			http.Error(w, "UserID not found in context", http.StatusUnauthorized)
+			return
+		}
+
+		shortURL := chi.URLParam(r, "short")
+
+		s, exists := store.Get(ctx, shortURL)
+		if !exists || s.UserID != userID {
+			http.Error(w, "URL not found", http.StatusNotFound)
+			return
+		}
+
+		history, err := store.GetURLHistory(ctx, shortURL)
+		if err != nil {
+			contextutils.Logger(ctx).Error("Failed to get URL history", "error", err)
+			http.Error(w, "Failed to get URL history", http.StatusInternalServerError)
+			return
+		}
+
+		result, err := json.Marshal(history)
+		if err != nil {
+			http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(result)
+	}
+}
+
+// RollbackURLHandler возвращает оригинальный URL к значению до указанного изменения,
+// а без change_id — до последнего. Откат записывается в историю как новое изменение.
+// Истёкший URL откатить нельзя
+func RollbackURLHandler(store store.Store, cfg config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+		defer cancel()
+
+		userID, ok := contextutils.GetUserID(ctx)
+		if !ok {
+			http.Error(w, "UserID not found in context", http.StatusUnauthorized)
+			return
+		}
+
+		body, ok := readBody(w, r, cfg)
+		if !ok {
+			return
+		}
+
+		var request models.RollbackRequest
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &request); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+		}
+
+		shortURL := chi.URLParam(r, "short")
+
+		s, exists := store.Get(ctx, shortURL)
+		if !exists || s.UserID != userID || s.DeletedFlag {
+			http.Error(w, "URL not found", http.StatusNotFound)
+			return
+		}
+		if s.Expired(time.Now()) {
+			http.Error(w, "URL is expired", http.StatusGone)
+			return
+		}
+
+		history, err := store.GetURLHistory(ctx, shortURL)
+		if err != nil {
+			contextutils.Logger(ctx).Error("Failed to get URL history", "error", err)
+			http.Error(w, "Failed to get URL history", http.StatusInternalServerError)
+			return
+		}
+		if len(history) == 0 {
+			http.Error(w, "URL has no changes to roll back", http.StatusConflict)
+			return
+		}
+
+		target := history[len(history)-1]
+		if request.ChangeID != "" {
+			found := false
+			for _, change := range history {
+				if change.ID == request.ChangeID {
+					target, found = change, true
+					break
+				}
+			}
+			if !found {
+				http.Error(w, "Change not found", http.StatusNotFound)
+				return
+			}
+		}
+
+		changeOriginalURL(ctx, w, store, cfg, s, target.OldURL)
+	}
+}
+
+// changeOriginalURL сохраняет новый оригинальный URL для s и отвечает его текущим состоянием.
+// Если URL не меняется, запись в историю не добавляется
+func changeOriginalURL(ctx context.Context, w http.ResponseWriter, store store.Store, cfg config.Config, s *models.Storage, originalURL string) {
+	if s.OriginalURL != originalURL {
+		_, err := store.UpdateOriginalURL(ctx, models.URLChange{
+			ID:        uuid.New().String(),
+			ShortURL:  s.ShortURL,
+			NewURL:    originalURL,
+			ChangedBy: s.UserID,
+			ChangedAt: time.Now().UTC(),
+		})
+		switch {
+		case err == nil:
+		case errors.Is(err, storeerrors.ErrURLNotFound):
+			http.Error(w, "URL not found", http.StatusNotFound)
+			return
+		case errors.Is(err, storeerrors.ErrURLExists):
+			// Оригинальный URL уже сокращён под другим коротким URL
+			result, _ := json.Marshal(models.Response{Result: cfg.BaseURL + "/" + conflictShortURL(err, "")})
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			w.Write(result)
+			return
+		default:
+			contextutils.Logger(ctx).Error("Failed to update original URL", "error", err)
+			http.Error(w, "Failed to update URL", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	result, err := json.Marshal(models.URL{
+		ShortURL:    cfg.BaseURL + "/" + s.ShortURL,
+		OriginalURL: originalURL,
+	})
+	if err != nil {
+		http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(result)
+}
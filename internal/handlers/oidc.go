@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/learies/go-url-shortener/internal/auth"
+	"github.com/learies/go-url-shortener/internal/contextutils"
+	"github.com/learies/go-url-shortener/internal/models"
+)
+
+// Кука с состоянием начатого входа через OIDC и время, за которое вход нужно завершить
+const (
+	oidcStateCookieName = "oidc_state"
+	oidcStateCookiePath = "/api/auth/oidc"
+	oidcStateLifetime   = 10 * time.Minute
+	// oidcStateAudience отличает состояние входа от токена пользователя, подписанного теми же ключами
+	oidcStateAudience = "oidc_state"
+)
+
+// oidcStateClaims состояние входа: state защищает от CSRF, nonce связывает ID-токен
+// с этой попыткой входа, verifier — секрет PKCE
+type oidcStateClaims struct {
+	jwt.RegisteredClaims
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+// OIDCLoginHandler начинает вход через провайдера OpenID Connect:
+// сохраняет состояние входа в подписанной куке и перенаправляет на страницу провайдера
+func OIDCLoginHandler(provider *auth.OIDCProvider, tokens *auth.TokenManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+		defer cancel()
+
+		state, err := auth.RandomString(16)
+		if err != nil {
+			http.Error(w, "Failed to start login", http.StatusInternalServerError)
+			return
+		}
+		nonce, err := auth.RandomString(16)
+		if err != nil {
+			http.Error(w, "Failed to start login", http.StatusInternalServerError)
+			return
+		}
+		verifier, challenge, err := auth.NewPKCE()
+		if err != nil {
+			http.Error(w, "Failed to start login", http.StatusInternalServerError)
+			return
+		}
+
+		authURL, err := provider.AuthCodeURL(ctx, state, nonce, challenge)
+		if err != nil {
+			contextutils.Logger(ctx).Error("Failed to reach OIDC provider", "error", err)
+			http.Error(w, "Identity provider is unavailable", http.StatusBadGateway)
+			return
+		}
+
+		expiresAt := time.Now().Add(oidcStateLifetime)
+		stateToken, err := tokens.Keys().Sign(&oidcStateClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Audience:  jwt.ClaimStrings{oidcStateAudience},
+				ExpiresAt: jwt.NewNumericDate(expiresAt),
+			},
+			State:    state,
+			Nonce:    nonce,
+			Verifier: verifier,
+		})
+		if err != nil {
+			http.Error(w, "Failed to start login", http.StatusInternalServerError)
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     oidcStateCookieName,
+			Value:    stateToken,
+			Expires:  expiresAt,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+			Path:     oidcStateCookiePath,
+		})
+		http.Redirect(w, r, authURL, http.StatusFound)
+	}
+}
+
+// OIDCCallbackHandler завершает вход: обменивает код на ID-токен, проверяет его
+// и выдаёт токен пользователя, чей user_id выводится из sub
+func OIDCCallbackHandler(provider *auth.OIDCProvider, tokens *auth.TokenManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+		defer cancel()
+
+		query := r.URL.Query()
+		if errorCode := query.Get("error"); errorCode != "" {
+			http.Error(w, "Login failed: "+errorCode, http.StatusUnauthorized)
+			return
+		}
+
+		cookie, err := r.Cookie(oidcStateCookieName)
+		if err != nil {
+			http.Error(w, "Login was not started", http.StatusBadRequest)
+			return
+		}
+		// Состояние одноразовое
+		http.SetCookie(w, &http.Cookie{
+			Name:     oidcStateCookieName,
+			Expires:  time.Unix(0, 0),
+			MaxAge:   -1,
+			HttpOnly: true,
+			Path:     oidcStateCookiePath,
+		})
+
+		var state oidcStateClaims
+		if _, err := tokens.Keys().Parse(cookie.Value, &state, jwt.WithExpirationRequired(), jwt.WithAudience(oidcStateAudience)); err != nil {
+			http.Error(w, "Invalid login state", http.StatusBadRequest)
+			return
+		}
+		if state.State == "" || subtle.ConstantTimeCompare([]byte(state.State), []byte(query.Get("state"))) != 1 {
+			http.Error(w, "Invalid login state", http.StatusBadRequest)
+			return
+		}
+
+		idToken, err := provider.Exchange(ctx, query.Get("code"), state.Verifier)
+		if err != nil {
+			contextutils.Logger(ctx).Error("Failed to exchange OIDC code", "error", err)
+			http.Error(w, "Login failed", http.StatusUnauthorized)
+			return
+		}
+
+		claims, err := provider.VerifyIDToken(ctx, idToken, state.Nonce)
+		if err != nil {
+			contextutils.Logger(ctx).Error("Invalid OIDC ID token", "error", err)
+			http.Error(w, "Login failed", http.StatusUnauthorized)
+			return
+		}
+
+		userID := provider.UserID(claims.Subject)
+		if err := tokens.SetCookie(w, userID, false); err != nil {
+			contextutils.Logger(ctx).Error("Could not create token", "error", err)
+			http.Error(w, "Could not create token", http.StatusInternalServerError)
+			return
+		}
+		contextutils.Logger(ctx).Info("User logged in with OIDC", "userID", userID)
+
+		login := claims.PreferredUsername
+		if login == "" {
+			login = claims.Email
+		}
+		result, err := json.Marshal(models.UserResponse{ID: userID, Login: login})
+		if err != nil {
+			http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(result)
+	}
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/learies/go-url-shortener/config"
+	"github.com/learies/go-url-shortener/internal/contextutils"
+	"github.com/learies/go-url-shortener/internal/models"
+	"github.com/learies/go-url-shortener/internal/store"
+)
+
+// Названия квот в ответах об их превышении
+const (
+	quotaLinks        = "links"
+	quotaBatchSize    = "batch_size"
+	quotaRequestBytes = "request_bytes"
+)
+
+// GetQuotaHandler возвращает квоты пользователя и их текущее использование
+func GetQuotaHandler(store store.Store, cfg config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+		defer cancel()
+
+		userID, ok := contextutils.GetUserID(ctx)
+		if !ok {
+			http.Error(w, "UserID not found in context", http.StatusUnauthorized)
+			return
+		}
+
+		links, err := store.CountUserUrls(ctx, userID)
+		if err != nil {
+			contextutils.Logger(ctx).Error("Failed to count user URLs", "error", err)
+			http.Error(w, "Failed to get quota", http.StatusInternalServerError)
+			return
+		}
+
+		result, err := json.Marshal(models.Quota{
+			Links:           links,
+			MaxLinks:        cfg.MaxLinksPerUser,
+			MaxBatchSize:    cfg.MaxBatchSize,
+			MaxRequestBytes: int64(cfg.MaxRequestBytes),
+		})
+		if err != nil {
+			http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(result)
+	}
+}
+
+// readBody читает тело запроса не длиннее cfg.MaxRequestBytes.
+// Если тело прочитать не удалось, ответ уже отправлен клиенту
+func readBody(w http.ResponseWriter, r *http.Request, cfg config.Config) ([]byte, bool) {
+	if r.Body == nil {
+		http.Error(w, "Empty request body", http.StatusBadRequest)
+		return nil, false
+	}
+	defer r.Body.Close()
+
+	reader := io.Reader(r.Body)
+	if cfg.MaxRequestBytes > 0 {
+		reader = http.MaxBytesReader(w, r.Body, int64(cfg.MaxRequestBytes))
+	}
+
+	body, err := io.ReadAll(reader)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeQuotaError(w, http.StatusRequestEntityTooLarge, models.QuotaError{
+			Error:     "Request body is too large",
+			Quota:     quotaRequestBytes,
+			Limit:     tooLarge.Limit,
+			Requested: max(r.ContentLength, 0),
+		})
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "Unable to read the request body", http.StatusInternalServerError)
+		return nil, false
+	}
+	return body, true
+}
+
+// checkBatchSize проверяет число элементов пакета по квоте cfg.MaxBatchSize
+func checkBatchSize(w http.ResponseWriter, cfg config.Config, size int) bool {
+	if cfg.MaxBatchSize <= 0 || size <= cfg.MaxBatchSize {
+		return true
+	}
+	writeQuotaError(w, http.StatusRequestEntityTooLarge, models.QuotaError{
+		Error:     "Batch has too many items",
+		Quota:     quotaBatchSize,
+		Limit:     int64(cfg.MaxBatchSize),
+		Requested: int64(size),
+	})
+	return false
+}
+
+// checkLinkQuota проверяет, что пользователь может создать ещё count ссылок.
+// originalURLs — URL из запроса, сокращаемые сгенерированным кодом: если URL уже
+// сокращён, новая ссылка не создаётся и квота на него не расходуется.
+// Параллельные запросы могут немного превысить квоту: проверка не атомарна с записью
+func checkLinkQuota(ctx context.Context, w http.ResponseWriter, store store.Store, cfg config.Config, userID string, count int, originalURLs ...string) bool {
+	if cfg.MaxLinksPerUser <= 0 {
+		return true
+	}
+
+	links, err := store.CountUserUrls(ctx, userID)
+	if err != nil {
+		contextutils.Logger(ctx).Error("Failed to count user URLs", "error", err)
+		http.Error(w, "Failed to check quota", http.StatusInternalServerError)
+		return false
+	}
+	if links+count <= cfg.MaxLinksPerUser {
+		return true
+	}
+
+	// Квота исчерпана: уже сокращённые URL получат существующие ссылки
+	for _, originalURL := range originalURLs {
+		shortURL, err := store.FindShortURL(ctx, originalURL)
+		if err != nil {
+			contextutils.Logger(ctx).Error("Failed to find short URL", "error", err)
+			http.Error(w, "Failed to check quota", http.StatusInternalServerError)
+			return false
+		}
+		if shortURL != "" {
+			count--
+		}
+	}
+	if links+count <= cfg.MaxLinksPerUser {
+		return true
+	}
+
+	writeQuotaError(w, http.StatusForbidden, models.QuotaError{
+		Error:     "Link quota exceeded",
+		Quota:     quotaLinks,
+		Limit:     int64(cfg.MaxLinksPerUser),
+		Usage:     int64(links),
+		Requested: int64(count),
+	})
+	return false
+}
+
+func writeQuotaError(w http.ResponseWriter, status int, quotaErr models.QuotaError) {
+	result, err := json.Marshal(quotaErr)
+	if err != nil {
+		http.Error(w, quotaErr.Error, status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(result)
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/learies/go-url-shortener/internal/auth"
+	"github.com/learies/go-url-shortener/internal/contextutils"
+	"github.com/learies/go-url-shortener/internal/models"
+	"github.com/learies/go-url-shortener/internal/store"
+	"github.com/learies/go-url-shortener/internal/store/storeerrors"
+)
+
+var loginPattern = regexp.MustCompile(`^[a-z0-9._@-]{3,64}$`)
+
+// RegisterHandler регистрирует пользователя и сразу выполняет вход.
+// С claim_links ссылки текущего анонимного пользователя переходят в новый аккаунт
+func RegisterHandler(store store.Store, tokens *auth.TokenManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+		defer cancel()
+
+		credentials, ok := decodeCredentials(w, r)
+		if !ok {
+			return
+		}
+		if !loginPattern.MatchString(credentials.Login) {
+			http.Error(w, "Login must be 3-64 characters: letters, digits, '.', '_', '@' or '-'", http.StatusBadRequest)
+			return
+		}
+
+		passwordHash, err := auth.HashPassword(credentials.Password)
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidPassword) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			http.Error(w, "Failed to hash password", http.StatusInternalServerError)
+			return
+		}
+
+		user := models.User{
+			ID:           uuid.New().String(),
+			Login:        credentials.Login,
+			PasswordHash: passwordHash,
+			CreatedAt:    time.Now().UTC(),
+		}
+		err = store.CreateUser(ctx, user)
+		if errors.Is(err, storeerrors.ErrLoginTaken) {
+			http.Error(w, "Login is already taken", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			contextutils.Logger(ctx).Error("Failed to create user", "error", err)
+			http.Error(w, "Failed to create user", http.StatusInternalServerError)
+			return
+		}
+
+		signIn(ctx, w, store, tokens, user, credentials.ClaimLinks, http.StatusCreated)
+	}
+}
+
+// LoginHandler выполняет вход по логину и паролю
+func LoginHandler(store store.Store, tokens *auth.TokenManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+		defer cancel()
+
+		credentials, ok := decodeCredentials(w, r)
+		if !ok {
+			return
+		}
+
+		user, err := store.GetUserByLogin(ctx, credentials.Login)
+		if err != nil && !errors.Is(err, storeerrors.ErrUserNotFound) {
+			contextutils.Logger(ctx).Error("Failed to get user", "error", err)
+			http.Error(w, "Failed to log in", http.StatusInternalServerError)
+			return
+		}
+
+		var passwordHash string
+		if user != nil {
+			passwordHash = user.PasswordHash
+		}
+		if !auth.CheckPassword(passwordHash, credentials.Password) {
+			http.Error(w, "Invalid login or password", http.StatusUnauthorized)
+			return
+		}
+
+		signIn(ctx, w, store, tokens, *user, credentials.ClaimLinks, http.StatusOK)
+	}
+}
+
+// LogoutHandler удаляет куку с токеном. Следующий запрос получит новую анонимную личность
+func LogoutHandler(tokens *auth.TokenManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tokens.ClearCookie(w)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func decodeCredentials(w http.ResponseWriter, r *http.Request) (models.Credentials, bool) {
+	var credentials models.Credentials
+	if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return credentials, false
+	}
+	defer r.Body.Close()
+
+	credentials.Login = strings.ToLower(strings.TrimSpace(credentials.Login))
+	return credentials, true
+}
+
+// signIn при необходимости переносит ссылки и ключи доступа анонимного пользователя
+// в аккаунт и выдаёт токен аккаунта
+func signIn(ctx context.Context, w http.ResponseWriter, store store.Store, tokens *auth.TokenManager,
+	user models.User, claimLinks bool, status int) {
+	response := models.UserResponse{ID: user.ID, Login: user.Login}
+
+	if currentUserID, ok := contextutils.GetUserID(ctx); ok && claimLinks && currentUserID != user.ID {
+		// Забрать можно только ссылки анонимной личности, но не другого аккаунта,
+		// в том числе аккаунта OIDC, которого нет среди зарегистрированных пользователей
+		if !contextutils.IsAnonymous(ctx) {
+			http.Error(w, "Only links of an anonymous user can be claimed", http.StatusConflict)
+			return
+		}
+
+		// Ключи переносятся первыми: ссылки без них анонимный пользователь
+		// ещё может забрать повторным запросом, а ключи после смены куки — уже нет
+		var err error
+		response.ClaimedAPIKeys, err = store.ClaimUserAPIKeys(ctx, currentUserID, user.ID)
+		if err != nil {
+			contextutils.Logger(ctx).Error("Failed to claim API keys", "error", err)
+			http.Error(w, "Failed to claim links", http.StatusInternalServerError)
+			return
+		}
+		response.ClaimedLinks, err = store.ClaimUserUrls(ctx, currentUserID, user.ID)
+		if err != nil {
+			contextutils.Logger(ctx).Error("Failed to claim links", "error", err)
+			http.Error(w, "Failed to claim links", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tokens.SetCookie(w, user.ID, false); err != nil {
+		contextutils.Logger(ctx).Error("Could not create token", "error", err)
+		http.Error(w, "Could not create token", http.StatusInternalServerError)
+		return
+	}
+
+	result, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(result)
+}
@@ -27,6 +27,6 @@ func Initialize(level string) error {
 		Level: logLevel,
 	})
 
-	Log = slog.New(handler)
+	Log = slog.New(traceHandler{Handler: handler})
 	return nil
 }
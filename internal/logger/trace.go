@@ -0,0 +1,37 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceHandler дополняет записи, сделанные с контекстом, идентификаторами трассы и спана.
+// Если trace_id уже добавлен в логгер через With, запись не дополняется
+type traceHandler struct {
+	slog.Handler
+	hasTraceID bool
+}
+
+func (h traceHandler) Handle(ctx context.Context, record slog.Record) error {
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() && !h.hasTraceID {
+		record.AddAttrs(
+			slog.String("trace_id", spanContext.TraceID().String()),
+			slog.String("span_id", spanContext.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	hasTraceID := h.hasTraceID
+	for _, attr := range attrs {
+		hasTraceID = hasTraceID || attr.Key == "trace_id"
+	}
+	return traceHandler{Handler: h.Handler.WithAttrs(attrs), hasTraceID: hasTraceID}
+}
+
+func (h traceHandler) WithGroup(name string) slog.Handler {
+	return traceHandler{Handler: h.Handler.WithGroup(name), hasTraceID: h.hasTraceID}
+}
@@ -0,0 +1,118 @@
+// Package metrics собирает метрики сервиса в формате Prometheus
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "shortener"
+
+// Результаты перехода по короткому URL
+const (
+	RedirectHit      = "hit"
+	RedirectNotFound = "not_found"
+	RedirectDeleted  = "deleted"
+	RedirectExpired  = "expired"
+)
+
+// Metrics метрики сервиса в собственном реестре
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	redirects       *prometheus.CounterVec
+	storeDuration   *prometheus.HistogramVec
+	storeErrors     *prometheus.CounterVec
+}
+
+// New создаёт реестр с метриками сервиса, среды выполнения Go и процесса
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route pattern, method and status code.",
+		}, []string{"route", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route pattern and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		redirects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "redirects_total",
+			Help:      "Short URL lookups by result: hit, not_found, deleted or expired.",
+		}, []string{"result"}),
+		storeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "store_operation_duration_seconds",
+			Help:      "Store operation latency by backend and operation.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"backend", "operation"}),
+		storeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "store_operation_errors_total",
+			Help:      "Failed store operations by backend and operation.",
+		}, []string{"backend", "operation"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.redirects,
+		m.storeDuration,
+		m.storeErrors,
+	)
+	return m
+}
+
+// Handler отдаёт метрики в формате Prometheus
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// ObserveRequest учитывает обработанный HTTP-запрос
+func (m *Metrics) ObserveRequest(route, method string, status int, duration time.Duration) {
+	m.requests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	m.requestDuration.WithLabelValues(route, method).Observe(duration.Seconds())
+}
+
+// ObserveRedirect учитывает переход по короткому URL
+func (m *Metrics) ObserveRedirect(result string) {
+	m.redirects.WithLabelValues(result).Inc()
+}
+
+// ObserveStoreOp учитывает операцию хранилища. err — только сбои, а не ожидаемые
+// результаты вроде «URL уже существует»
+func (m *Metrics) ObserveStoreOp(backend, operation string, duration time.Duration, err error) {
+	m.storeDuration.WithLabelValues(backend, operation).Observe(duration.Seconds())
+	if err != nil {
+		m.storeErrors.WithLabelValues(backend, operation).Inc()
+	}
+}
+
+// RegisterDBStats добавляет статистику пула соединений с базой данных
+func (m *Metrics) RegisterDBStats(db *sql.DB) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, "urls"))
+}
+
+// RegisterDeletionQueue добавляет глубину очереди удаления URL
+func (m *Metrics) RegisterDeletionQueue(depth func() int) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "deletion_queue_depth",
+		Help:      "URLs waiting to be marked as deleted.",
+	}, func() float64 { return float64(depth()) }))
+}
@@ -0,0 +1,66 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/learies/go-url-shortener/internal/auth"
+	"github.com/learies/go-url-shortener/internal/contextutils"
+	"github.com/learies/go-url-shortener/internal/models"
+	"github.com/learies/go-url-shortener/internal/store/storeerrors"
+)
+
+// APIKeyFinder хранилище, умеющее искать ключи доступа по хэшу
+type APIKeyFinder interface {
+	GetAPIKey(ctx context.Context, keyHash string) (*models.APIKey, error)
+}
+
+// APIKeyMiddleware определяет пользователя по ключу доступа из заголовка
+// X-API-Key или Authorization: Bearer. Запросы без ключа передаются дальше
+// без изменений, чтобы пользователя определил JWTMiddleware
+func APIKeyMiddleware(keys APIKeyFinder) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := requestAPIKey(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !auth.IsAPIKey(key) {
+				http.Error(w, "Invalid API key", http.StatusUnauthorized)
+				return
+			}
+
+			apiKey, err := keys.GetAPIKey(r.Context(), auth.HashAPIKey(key))
+			if err != nil {
+				if errors.Is(err, storeerrors.ErrAPIKeyNotFound) {
+					http.Error(w, "Invalid API key", http.StatusUnauthorized)
+					return
+				}
+				contextutils.Logger(r.Context()).Error("Failed to look up API key", "error", err)
+				http.Error(w, "Failed to check API key", http.StatusInternalServerError)
+				return
+			}
+
+			contextutils.Logger(r.Context()).Info("Got user ID from API key", "userID", apiKey.UserID, "keyID", apiKey.ID)
+			ctx := contextutils.WithUserID(r.Context(), apiKey.UserID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// requestAPIKey достаёт ключ доступа из заголовков запроса
+func requestAPIKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if found && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
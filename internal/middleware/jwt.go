@@ -1,83 +1,92 @@
 package middlewares
 
 import (
+	"context"
+	"errors"
 	"net/http"
-	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 
 	"github.com/google/uuid"
+	"github.com/learies/go-url-shortener/internal/auth"
 	"github.com/learies/go-url-shortener/internal/contextutils"
-	"github.com/learies/go-url-shortener/internal/logger"
 )
 
-type Claims struct {
-	jwt.RegisteredClaims
-	UserID string `json:"user_id"`
-}
-
-func createUserID() string {
+func createUserID(ctx context.Context) string {
 	userID := uuid.New().String()
-	logger.Log.Info("Created new user ID", "userID", userID)
+	contextutils.Logger(ctx).Info("Created new user ID", "userID", userID)
 	return userID
 }
 
-func JWTMiddleware(next http.Handler) http.Handler {
+// JWTMiddleware определяет пользователя по токену из куки. Новым пользователям
+// и пользователям, чей токен скоро истечёт или истёк в пределах grace-периода,
+// выдаётся новый токен
+func JWTMiddleware(tokens *auth.TokenManager) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return jwtHandler(tokens, next)
+	}
+}
+
+func jwtHandler(tokens *auth.TokenManager, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Пользователь уже определён другим способом, например ключом доступа
+		if _, ok := contextutils.GetUserID(r.Context()); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		var userID string
+		var anonymous bool
 		var tokenString string
 
 		// Чтение токена из куки
-		cookie, err := r.Cookie("token")
+		cookie, err := r.Cookie(auth.TokenCookieName)
 		if err == nil {
 			tokenString = cookie.Value
 		}
 
-		// Если токен не передан нужно создать userID и создать для него токен
-		if tokenString == "" {
-			logger.Log.Error("No token provided")
-			userID = createUserID()
-
-			// Время жизни токена 1 минута
-			expirationTime := time.Now().Add(1 * time.Minute)
-
-			claims := &Claims{
-				UserID: userID,
-				RegisteredClaims: jwt.RegisteredClaims{
-					ExpiresAt: jwt.NewNumericDate(expirationTime),
-				},
+		renew := true
+		if tokenString != "" {
+			var claims *auth.Claims
+			claims, renew, err = tokens.Authenticate(tokenString)
+			switch {
+			case err == nil:
+				userID, anonymous = claims.UserID, claims.Anonymous
+				contextutils.Logger(r.Context()).Info("Got user ID from token in cookie", "userID", userID)
+			case errors.Is(err, jwt.ErrTokenExpired) && !tokens.RejectsExpired():
+				contextutils.Logger(r.Context()).Info("Token expired beyond grace period, creating new identity")
+				renew = true
+			case errors.Is(err, jwt.ErrTokenExpired):
+				// Удаляем куку, чтобы следующий запрос получил новую личность
+				tokens.ClearCookie(w)
+				http.Error(w, "Token expired", http.StatusUnauthorized)
+				return
+			default:
+				// Токен с неизвестным kid или неверной подписью (например, после смены
+				// ключей) не должен запирать пользователя: выдаём новую личность
+				// и перезаписываем куку, как при отсутствии токена
+				contextutils.Logger(r.Context()).Info("Rejected token, creating new identity", "error", err)
+				userID, renew = "", true
 			}
+		}
 
-			token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		// Если токена нет, он истёк или не прошёл проверку, нужно создать userID
+		if userID == "" {
+			userID, anonymous = createUserID(r.Context()), true
+		}
 
-			tokenString, err := token.SignedString([]byte("qwerty"))
-			if err != nil {
+		if renew {
+			if err := tokens.SetCookie(w, userID, anonymous); err != nil {
+				contextutils.Logger(r.Context()).Error("Could not create token", "error", err)
 				http.Error(w, "Could not create token", http.StatusInternalServerError)
 				return
 			}
-
-			// Устанавливаем токен в куки
-			http.SetCookie(w, &http.Cookie{
-				Name:     "token",
-				Value:    tokenString,
-				Expires:  expirationTime,
-				HttpOnly: true,
-				Path:     "/",
-			})
-		} else {
-			claims := &Claims{}
-			token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
-				return []byte("qwerty"), nil
-			})
-			if err != nil || !token.Valid {
-				http.Error(w, "Invalid token", http.StatusUnauthorized)
-				return
-			}
-			userID = claims.UserID
-			logger.Log.Info("Got user ID from token in cookie", "userID", userID)
 		}
 
 		ctx := contextutils.WithUserID(r.Context(), userID)
+		if anonymous {
+			ctx = contextutils.WithAnonymous(ctx)
+		}
 		r = r.WithContext(ctx)
 
 		next.ServeHTTP(w, r)
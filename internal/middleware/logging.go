@@ -5,7 +5,7 @@ import (
 	"sync"
 	"time"
 
-	"github.com/learies/go-url-shortener/internal/logger"
+	"github.com/learies/go-url-shortener/internal/contextutils"
 )
 
 type ResponseWriter interface {
@@ -70,7 +70,7 @@ func WithLogging(next http.Handler) http.Handler {
 
 		duration := time.Since(start)
 
-		logger.Log.Info("Request completed",
+		contextutils.Logger(r.Context()).Info("Request completed",
 			"uri", r.RequestURI,
 			"method", r.Method,
 			"status", responseData.status,
@@ -0,0 +1,40 @@
+package middlewares
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// RequestObserver получатель метрик обработанных HTTP-запросов
+type RequestObserver interface {
+	ObserveRequest(route, method string, status int, duration time.Duration)
+}
+
+// MetricsMiddleware сообщает observer о каждом запросе. Запросы группируются по шаблону
+// маршрута chi, а не по пути, чтобы короткие URL не порождали отдельные серии
+func MetricsMiddleware(observer RequestObserver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			responseData := &responseData{}
+			lw := &loggingResponseWriter{
+				ResponseWriter: w,
+				responseData:   responseData,
+			}
+
+			next.ServeHTTP(lw, r)
+
+			route := "unmatched"
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+			status := responseData.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			observer.ObserveRequest(route, r.Method, status, time.Since(start))
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package middlewares
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/learies/go-url-shortener/internal/contextutils"
+	"github.com/learies/go-url-shortener/internal/ratelimit"
+)
+
+// RateLimitMiddleware ограничивает частоту запросов отдельно для IP-адреса клиента
+// и для пользователя, поэтому должен стоять после определения пользователя.
+// У каждого класса маршрутов class (например, create и redirect) свои корзины,
+// и расход одного бюджета не влияет на другой.
+// Ответ содержит заголовки RateLimit-* по самой исчерпанной корзине, отклонённый
+// запрос получает 429 с Retry-After. Если лимитер недоступен, запрос пропускается
+func RateLimitMiddleware(limiter ratelimit.Limiter, class string, limit ratelimit.Limit) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			keys := []string{class + ":ip:" + clientIP(r)}
+			if userID, ok := contextutils.GetUserID(r.Context()); ok {
+				keys = append(keys, class+":user:"+userID)
+			}
+
+			var tightest *ratelimit.Result
+			for _, key := range keys {
+				result, err := limiter.Allow(r.Context(), key, limit)
+				if err != nil {
+					contextutils.Logger(r.Context()).Error("Rate limiter failed, letting request through", "key", key, "error", err)
+					next.ServeHTTP(w, r)
+					return
+				}
+				if tightest == nil || !result.Allowed || (tightest.Allowed && result.Remaining < tightest.Remaining) {
+					tightest = &result
+				}
+				if !result.Allowed {
+					break
+				}
+			}
+
+			setRateLimitHeaders(w, *tightest)
+			if !tightest.Allowed {
+				contextutils.Logger(r.Context()).Info("Rate limit exceeded", "keys", keys, "retryAfter", tightest.RetryAfter)
+				w.Header().Set("Retry-After", strconv.Itoa(seconds(tightest.RetryAfter)))
+				http.Error(w, "Too many requests", http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func setRateLimitHeaders(w http.ResponseWriter, result ratelimit.Result) {
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(seconds(result.Reset)))
+}
+
+// seconds округляет длительность вверх до целых секунд
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// clientIP возвращает IP-адрес клиента без порта
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,103 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/learies/go-url-shortener/internal/contextutils"
+	"github.com/learies/go-url-shortener/internal/logger"
+	"github.com/learies/go-url-shortener/internal/ratelimit"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	if err := logger.Initialize("error"); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := RateLimitMiddleware(ratelimit.NewMemoryLimiter(), "create", ratelimit.Limit{PerMinute: 1, Burst: 2})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+	request := func(remoteAddr, userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+		req.RemoteAddr = remoteAddr
+		if userID != "" {
+			req = req.WithContext(contextutils.WithUserID(req.Context(), userID))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := request("192.0.2.1:1234", "user-1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", rec.Header().Get("RateLimit-Remaining"))
+
+	rec = request("192.0.2.1:5678", "user-2")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+
+	// Бюджет IP исчерпан, хотя пользователь новый
+	rec = request("192.0.2.1:1234", "user-3")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+
+	// Бюджет пользователя исчерпан, хотя IP другой
+	assert.Equal(t, http.StatusOK, request("198.51.100.7:1234", "user-1").Code)
+	rec = request("203.0.113.9:1234", "user-1")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+}
+
+func TestRateLimitMiddlewareClasses(t *testing.T) {
+	if err := logger.Initialize("error"); err != nil {
+		t.Fatal(err)
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	newHandlers := func() (create, redirect http.Handler) {
+		limiter := ratelimit.NewMemoryLimiter()
+		create = RateLimitMiddleware(limiter, "create", ratelimit.Limit{PerMinute: 1, Burst: 1})(ok)
+		redirect = RateLimitMiddleware(limiter, "redirect", ratelimit.Limit{PerMinute: 1, Burst: 3})(ok)
+		return create, redirect
+	}
+
+	request := func(handler http.Handler) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req = req.WithContext(contextutils.WithUserID(req.Context(), "user-1"))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("spent redirect budget does not block creation", func(t *testing.T) {
+		create, redirect := newHandlers()
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, http.StatusOK, request(redirect).Code)
+		}
+		assert.Equal(t, http.StatusTooManyRequests, request(redirect).Code)
+
+		rec := request(create)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get("RateLimit-Limit"))
+	})
+
+	t.Run("spent creation budget does not block redirects", func(t *testing.T) {
+		create, redirect := newHandlers()
+		assert.Equal(t, http.StatusOK, request(create).Code)
+		assert.Equal(t, http.StatusTooManyRequests, request(create).Code)
+
+		rec := request(redirect)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "3", rec.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "2", rec.Header().Get("RateLimit-Remaining"))
+	})
+}
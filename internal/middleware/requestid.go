@@ -0,0 +1,53 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/learies/go-url-shortener/internal/contextutils"
+	"github.com/learies/go-url-shortener/internal/logger"
+)
+
+// RequestIDHeader заголовок с идентификатором запроса
+const RequestIDHeader = "X-Request-ID"
+
+// Максимальная длина идентификатора запроса, принимаемого от клиента
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware берёт идентификатор запроса из X-Request-ID или создаёт новый,
+// возвращает его в ответе и кладёт в контекст логгер запроса с request_id и trace_id.
+// user_id добавляется в логгер, когда пользователь определён, route — когда выбран маршрут
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !isValidRequestID(requestID) {
+			requestID = uuid.New().String()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		ctx := contextutils.WithRequestID(r.Context(), requestID)
+		requestLogger := logger.Log.With("request_id", requestID)
+		if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+			requestLogger = requestLogger.With("trace_id", spanContext.TraceID().String())
+		}
+		ctx = contextutils.WithLogger(ctx, requestLogger)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// isValidRequestID принимает непустые идентификаторы из печатных ASCII-символов,
+// чтобы клиент не мог подделать строки журнала
+func isValidRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] < 0x21 || requestID[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
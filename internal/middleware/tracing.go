@@ -0,0 +1,95 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/learies/go-url-shortener/internal/tracing"
+)
+
+// parentSpanKey ключ контекста со спаном, внутри которого выполняется цепочка middleware
+type parentSpanKey struct{}
+
+// TracingMiddleware продолжает трассу из заголовков traceparent/tracestate запроса
+// или начинает новую и оборачивает запрос серверным спаном.
+// Спан получает имя по шаблону маршрута chi, когда маршрут уже известен
+func TracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracing.Tracer().Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+				semconv.ClientAddress(clientIP(r)),
+			),
+		)
+		defer span.End()
+
+		responseData := &responseData{}
+		lw := &loggingResponseWriter{
+			ResponseWriter: w,
+			responseData:   responseData,
+		}
+
+		next.ServeHTTP(lw, r.WithContext(ctx))
+
+		if rctx := chi.RouteContext(ctx); rctx != nil && rctx.RoutePattern() != "" {
+			span.SetName(r.Method + " " + rctx.RoutePattern())
+			span.SetAttributes(semconv.HTTPRoute(rctx.RoutePattern()))
+		}
+		status := responseData.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
+
+// TracedMiddleware оборачивает middleware спаном name. Спан заканчивается, когда
+// middleware передаёт запрос дальше, поэтому в него попадает только работа
+// самого middleware, а следующие звенья цепочки становятся соседними спанами
+func TracedMiddleware(name string, middleware func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		wrapped := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			trace.SpanFromContext(ctx).End()
+			parent, _ := ctx.Value(parentSpanKey{}).(trace.Span)
+			next.ServeHTTP(w, r.WithContext(trace.ContextWithSpan(ctx, parent)))
+		}))
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), parentSpanKey{}, trace.SpanFromContext(r.Context()))
+			ctx, span := tracing.Tracer().Start(ctx, "middleware "+name)
+			// Если middleware сам ответил на запрос, спан заканчивается здесь
+			defer span.End()
+
+			wrapped.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// HandlerSpan оборачивает спаном обработку запроса после цепочки middleware.
+// Маршрут выбирается внутри next, поэтому имя спана уточняется по его шаблону в конце
+func HandlerSpan(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracing.Tracer().Start(r.Context(), "handler")
+		defer span.End()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+
+		if rctx := chi.RouteContext(ctx); rctx != nil && rctx.RoutePattern() != "" {
+			span.SetName("handler " + rctx.RoutePattern())
+		}
+	})
+}
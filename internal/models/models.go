@@ -1,36 +1,111 @@
 package models
 
+import "time"
+
 type Storage struct {
-	ID          string `db:"id" json:"id"`
-	ShortURL    string `db:"short_url" json:"short_url"`
-	OriginalURL string `db:"original_url" json:"original_url"`
-	UserID      string `db:"user_id" json:"user_id"`
-	DeletedFlag bool   `db:"is_deleted" json:"is_deleted"`
+	ID          string     `db:"id" json:"id"`
+	ShortURL    string     `db:"short_url" json:"short_url"`
+	OriginalURL string     `db:"original_url" json:"original_url"`
+	UserID      string     `db:"user_id" json:"user_id"`
+	DeletedFlag bool       `db:"is_deleted" json:"is_deleted"`
+	ExpiresAt   *time.Time `db:"expires_at" json:"expires_at,omitempty"`
+	// RedirectType код перенаправления для URL, 0 — код по умолчанию из конфигурации
+	RedirectType int `db:"redirect_type" json:"redirect_type,omitempty"`
+	// IsAlias короткий URL задан пользователем, а не сгенерирован
+	IsAlias bool `db:"is_alias" json:"is_alias,omitempty"`
+}
+
+// ValidRedirectType сообщает, можно ли отвечать кодом code при переходе по короткому URL
+func ValidRedirectType(code int) bool {
+	switch code {
+	case 301, 302, 307, 308:
+		return true
+	}
+	return false
+}
+
+// Expired сообщает, истёк ли срок действия URL к моменту now
+func (s *Storage) Expired(now time.Time) bool {
+	return s.ExpiresAt != nil && !now.Before(*s.ExpiresAt)
 }
 
 type Request struct {
-	URL string `json:"url"`
+	URL        string     `json:"url"`
+	Alias      string     `json:"alias,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	TTLSeconds int64      `json:"ttl_seconds,omitempty"`
+	// RedirectType код перенаправления: 301, 302, 307 или 308
+	RedirectType int `json:"redirect_type,omitempty"`
 }
 
 type Response struct {
 	Result string `json:"result"`
 }
 
+// UpdateURLRequest запрос на изменение оригинального URL
+type UpdateURLRequest struct {
+	OriginalURL string `json:"original_url"`
+}
+
+// RollbackRequest запрос на откат оригинального URL к значению до изменения ChangeID.
+// Без ChangeID откатывается последнее изменение
+type RollbackRequest struct {
+	ChangeID string `json:"change_id,omitempty"`
+}
+
+// URLChange запись истории изменений оригинального URL
+type URLChange struct {
+	ID        string    `db:"id" json:"id"`
+	ShortURL  string    `db:"short_url" json:"short_url"`
+	OldURL    string    `db:"old_url" json:"old_url"`
+	NewURL    string    `db:"new_url" json:"new_url"`
+	ChangedBy string    `db:"changed_by" json:"changed_by"`
+	ChangedAt time.Time `db:"changed_at" json:"changed_at"`
+}
+
 type BatchURLRequest struct {
-	CorrelationID string `json:"correlation_id"`
-	OriginalURL   string `json:"original_url"`
+	CorrelationID string     `json:"correlation_id"`
+	OriginalURL   string     `json:"original_url"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	TTLSeconds    int64      `json:"ttl_seconds,omitempty"`
 }
 
+// Статусы элементов пакетного сокращения
+const (
+	// BatchStatusCreated короткий URL создан
+	BatchStatusCreated = "created"
+	// BatchStatusExists оригинальный URL уже сокращён, возвращается существующий короткий URL
+	BatchStatusExists = "exists"
+	// BatchStatusTaken сгенерированный короткий URL занят другим URL, нужна новая попытка
+	BatchStatusTaken = "taken"
+	// BatchStatusInvalid элемент не прошёл проверку
+	BatchStatusInvalid = "invalid"
+	// BatchStatusAborted элемент не сохранён, потому что пакет отменён целиком
+	BatchStatusAborted = "aborted"
+	// BatchStatusFailed не удалось подобрать свободный короткий URL
+	BatchStatusFailed = "failed"
+)
+
 type BatchURLResponse struct {
 	CorrelationID string `json:"correlation_id"`
-	ShortURL      string `json:"short_url"`
+	ShortURL      string `json:"short_url,omitempty"`
+	Status        string `json:"status"`
+	Error         string `json:"error,omitempty"`
 }
 
-type BatchURLWrite struct {
+// BatchURLResult результат сохранения элемента пакета в хранилище
+type BatchURLResult struct {
 	CorrelationID string `json:"correlation_id"`
 	ShortURL      string `json:"short_url"`
-	OriginalURL   string `json:"original_url"`
-	UserID        string `json:"user_id"`
+	Status        string `json:"status"`
+}
+
+type BatchURLWrite struct {
+	CorrelationID string     `json:"correlation_id"`
+	ShortURL      string     `json:"short_url"`
+	OriginalURL   string     `json:"original_url"`
+	UserID        string     `json:"user_id"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
 }
 
 type URL struct {
@@ -46,3 +121,91 @@ type UserURL struct {
 type ShortURLs struct {
 	ShortURLs []string `json:"short_urls"`
 }
+
+// Click событие перехода по короткому URL. IP хранится в анонимизированном виде
+type Click struct {
+	ShortURL  string    `db:"short_url" json:"short_url"`
+	Timestamp time.Time `db:"clicked_at" json:"timestamp"`
+	Referrer  string    `db:"referrer" json:"referrer,omitempty"`
+	UserAgent string    `db:"user_agent" json:"user_agent,omitempty"`
+	IP        string    `db:"ip" json:"ip,omitempty"`
+}
+
+type ClickStats struct {
+	ShortURL       string        `json:"short_url"`
+	TotalClicks    int64         `json:"total_clicks"`
+	UniqueVisitors int64         `json:"unique_visitors"`
+	Daily          []DailyClicks `json:"daily"`
+}
+
+type DailyClicks struct {
+	Date           string `json:"date"`
+	Clicks         int64  `json:"clicks"`
+	UniqueVisitors int64  `json:"unique_visitors"`
+}
+
+// APIKey ключ доступа к API для программных клиентов.
+// Сам ключ не хранится, только его SHA-256 хэш
+type APIKey struct {
+	ID        string     `db:"id" json:"id"`
+	UserID    string     `db:"user_id" json:"user_id"`
+	Name      string     `db:"name" json:"name"`
+	Prefix    string     `db:"prefix" json:"prefix"`
+	KeyHash   string     `db:"key_hash" json:"key_hash"`
+	CreatedAt time.Time  `db:"created_at" json:"created_at"`
+	RevokedAt *time.Time `db:"revoked_at" json:"revoked_at,omitempty"`
+}
+
+type APIKeyRequest struct {
+	Name string `json:"name"`
+}
+
+// APIKeyResponse описание ключа доступа. Key заполняется только при создании
+type APIKeyResponse struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Prefix    string    `json:"prefix"`
+	CreatedAt time.Time `json:"created_at"`
+	Key       string    `json:"key,omitempty"`
+}
+
+// User зарегистрированный пользователь. ID совпадает с user_id его URL
+type User struct {
+	ID           string    `db:"id" json:"id"`
+	Login        string    `db:"login" json:"login"`
+	PasswordHash string    `db:"password_hash" json:"password_hash"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+}
+
+// Credentials данные для регистрации и входа. ClaimLinks переносит
+// в аккаунт ссылки текущего анонимного пользователя
+type Credentials struct {
+	Login      string `json:"login"`
+	Password   string `json:"password"`
+	ClaimLinks bool   `json:"claim_links,omitempty"`
+}
+
+type UserResponse struct {
+	ID             string `json:"id"`
+	Login          string `json:"login"`
+	ClaimedLinks   int64  `json:"claimed_links"`
+	ClaimedAPIKeys int64  `json:"claimed_api_keys"`
+}
+
+// Quota лимиты пользователя и текущее использование. Ноль в Max* означает отсутствие ограничения
+type Quota struct {
+	Links           int   `json:"links"`
+	MaxLinks        int   `json:"max_links"`
+	MaxBatchSize    int   `json:"max_batch_size"`
+	MaxRequestBytes int64 `json:"max_request_bytes"`
+}
+
+// QuotaError ответ на запрос, превысивший квоту: Usage — использование до запроса,
+// Requested — сколько запрошено
+type QuotaError struct {
+	Error     string `json:"error"`
+	Quota     string `json:"quota"`
+	Limit     int64  `json:"limit"`
+	Usage     int64  `json:"usage"`
+	Requested int64  `json:"requested,omitempty"`
+}
@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Число корзин, после которого из памяти убираются полные корзины
+const memorySweepThreshold = 10000
+
+// MemoryLimiter хранит корзины в памяти процесса
+type MemoryLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+// NewMemoryLimiter создаёт лимитер с корзинами в памяти
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow списывает токен из корзины key
+func (l *MemoryLimiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, exists := l.buckets[key]
+	if !exists {
+		if len(l.buckets) >= memorySweepThreshold {
+			l.sweep(now)
+		}
+		b = newBucket(now, limit)
+		l.buckets[key] = b
+	}
+	return b.take(now, limit), nil
+}
+
+// sweep убирает корзины, которые успели бы наполниться полностью:
+// новая корзина для того же ключа будет в том же состоянии
+func (l *MemoryLimiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if now.Sub(b.updated) > time.Hour {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryLimiter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewMemoryLimiter()
+	limiter.now = func() time.Time { return now }
+	limit := Limit{PerMinute: 60, Burst: 3}
+
+	for i := 2; i >= 0; i-- {
+		result, err := limiter.Allow(ctx, "ip:192.0.2.1", limit)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 3, result.Limit)
+		assert.Equal(t, i, result.Remaining)
+	}
+
+	result, err := limiter.Allow(ctx, "ip:192.0.2.1", limit)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, time.Second, result.RetryAfter)
+	assert.Equal(t, 3*time.Second, result.Reset)
+
+	// Другие ключи считаются отдельно
+	result, err = limiter.Allow(ctx, "user:42", limit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	// Токены восстанавливаются со временем, но не сверх burst
+	now = now.Add(time.Second)
+	result, err = limiter.Allow(ctx, "ip:192.0.2.1", limit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+
+	now = now.Add(time.Hour)
+	result, err = limiter.Allow(ctx, "ip:192.0.2.1", limit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 2, result.Remaining)
+}
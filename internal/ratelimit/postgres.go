@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+	"time"
+
+	"github.com/learies/go-url-shortener/internal/logger"
+)
+
+// Каждый postgresCleanupEvery-й вызов Allow удаляет давно не использованные корзины
+const postgresCleanupEvery = 1000
+
+// PostgresLimiter хранит корзины в таблице rate_limits, общей для всех экземпляров
+// сервиса. Время берётся из базы, чтобы расхождение часов экземпляров не влияло на лимиты
+type PostgresLimiter struct {
+	db    *sql.DB
+	calls atomic.Uint64
+}
+
+// NewPostgresLimiter создаёт лимитер с корзинами в Postgres
+func NewPostgresLimiter(db *sql.DB) *PostgresLimiter {
+	return &PostgresLimiter{db: db}
+}
+
+// Allow списывает токен из корзины key
+func (l *PostgresLimiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	if l.calls.Add(1)%postgresCleanupEvery == 0 {
+		go l.cleanup()
+	}
+
+	tx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Result{}, err
+	}
+	defer tx.Rollback()
+
+	// Новая корзина создаётся полной; для существующей строка блокируется до конца транзакции
+	query := `
+	INSERT INTO rate_limits (key, tokens, updated_at)
+	VALUES ($1, $2, now())
+	ON CONFLICT (key) DO NOTHING`
+	if _, err := tx.ExecContext(ctx, query, key, limit.Burst); err != nil {
+		return Result{}, err
+	}
+
+	var b bucket
+	var now time.Time
+	query = `
+	SELECT tokens, updated_at, now()
+	FROM rate_limits
+	WHERE key = $1
+	FOR UPDATE`
+	if err := tx.QueryRowContext(ctx, query, key).Scan(&b.tokens, &b.updated, &now); err != nil {
+		return Result{}, err
+	}
+
+	result := b.take(now, limit)
+
+	query = `UPDATE rate_limits SET tokens = $2, updated_at = $3 WHERE key = $1`
+	if _, err := tx.ExecContext(ctx, query, key, b.tokens, b.updated); err != nil {
+		return Result{}, err
+	}
+	return result, tx.Commit()
+}
+
+// cleanup удаляет корзины, которые успели бы наполниться полностью
+func (l *PostgresLimiter) cleanup() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM rate_limits WHERE updated_at < now() - interval '1 hour'`
+	if _, err := l.db.ExecContext(ctx, query); err != nil {
+		logger.Log.Error("Failed to clean up rate limits", "error", err)
+	}
+}
@@ -0,0 +1,71 @@
+// Package ratelimit ограничивает частоту запросов по алгоритму token bucket.
+// Корзины хранятся в памяти процесса или в Postgres, если экземпляров сервиса несколько
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit бюджет запросов: корзина вмещает Burst токенов и пополняется
+// со скоростью PerMinute токенов в минуту
+type Limit struct {
+	PerMinute int
+	Burst     int
+}
+
+// Result результат проверки лимита
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter через сколько запрос будет разрешён, если он отклонён
+	RetryAfter time.Duration
+	// Reset через сколько корзина наполнится полностью
+	Reset time.Duration
+}
+
+// Limiter списывает токены из корзины key
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// bucket состояние корзины на момент updated
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// take пополняет корзину к моменту now и списывает из неё один токен, если он есть
+func (b *bucket) take(now time.Time, limit Limit) Result {
+	rate := float64(limit.PerMinute) / float64(time.Minute)
+	burst := float64(limit.Burst)
+
+	elapsed := now.Sub(b.updated)
+	if elapsed > 0 {
+		b.tokens = math.Min(burst, b.tokens+float64(elapsed)*rate)
+		b.updated = now
+	}
+
+	result := Result{Limit: limit.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else if rate > 0 {
+		result.RetryAfter = time.Duration(math.Ceil((1 - b.tokens) / rate))
+	} else {
+		result.RetryAfter = time.Minute
+	}
+
+	result.Remaining = int(b.tokens)
+	if rate > 0 {
+		result.Reset = time.Duration(math.Ceil((burst - b.tokens) / rate))
+	}
+	return result
+}
+
+// newBucket создаёт полную корзину
+func newBucket(now time.Time, limit Limit) *bucket {
+	return &bucket{tokens: float64(limit.Burst), updated: now}
+}
@@ -2,46 +2,103 @@ package router
 
 import (
 	"net/http"
-	"os"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
 
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/chi/v5"
 	"github.com/learies/go-url-shortener/config"
+	"github.com/learies/go-url-shortener/internal/auth"
 	"github.com/learies/go-url-shortener/internal/handlers"
-	"github.com/learies/go-url-shortener/internal/logger"
+	"github.com/learies/go-url-shortener/internal/metrics"
 	internalMiddleware "github.com/learies/go-url-shortener/internal/middleware"
+	"github.com/learies/go-url-shortener/internal/ratelimit"
 	"github.com/learies/go-url-shortener/internal/shortener"
 	"github.com/learies/go-url-shortener/internal/store"
+	"github.com/learies/go-url-shortener/internal/worker"
 )
 
-func NewRouter(cfg config.Config) http.Handler {
-	store, err := store.NewStore(cfg)
-	if err != nil {
-		logger.Log.Error("Error creating store", "err", err)
-		os.Exit(1)
-	}
+// Dependencies зависимости обработчиков HTTP API
+type Dependencies struct {
+	Store     store.Store
+	Shortener shortener.Generator
+	Clicks    *worker.ClickRecorder
+	Deleter   *worker.Deleter
+	Tokens    *auth.TokenManager
+	// OIDC провайдер для входа через OpenID Connect, nil — вход отключён
+	OIDC *auth.OIDCProvider
+	// Limiter лимитер запросов на создание ссылок и переходы, nil — без ограничений
+	Limiter ratelimit.Limiter
+	Metrics *metrics.Metrics
+}
+
+// NewRouter собирает обработчики HTTP API поверх готовых зависимостей
+func NewRouter(cfg config.Config, deps Dependencies) http.Handler {
+	store, urlShortener := deps.Store, deps.Shortener
 
-	urlShortener := shortener.NewURLShortener()
+	traced := internalMiddleware.TracedMiddleware
 
 	r := chi.NewRouter()
-	r.Use(middleware.Recoverer)
-	r.Use(internalMiddleware.WithLogging)
-	r.Use(internalMiddleware.GzipMiddleware)
-	r.Use(internalMiddleware.JWTMiddleware)
-
-	r.Post("/", handlers.PostHandler(store, cfg, urlShortener))
-	r.Post("/api/shorten", handlers.PostAPIHandler(store, cfg, urlShortener))
-	r.Post("/api/shorten/batch", handlers.PostAPIBatchHandler(store, cfg, urlShortener))
+	r.Use(internalMiddleware.TracingMiddleware)
+	r.Use(internalMiddleware.RequestIDMiddleware)
+	r.Use(internalMiddleware.MetricsMiddleware(deps.Metrics))
+	r.Use(traced("recoverer", middleware.Recoverer))
+	r.Use(traced("logging", internalMiddleware.WithLogging))
+	r.Use(traced("gzip", internalMiddleware.GzipMiddleware))
+	r.Use(traced("api_key", internalMiddleware.APIKeyMiddleware(store)))
+	r.Use(traced("jwt", internalMiddleware.JWTMiddleware(deps.Tokens)))
+	r.Use(internalMiddleware.HandlerSpan)
+
+	createLimit := rateLimit(deps.Limiter, "create", cfg.RateLimitCreatePerMinute, cfg.RateLimitCreateBurst)
+	redirectLimit := rateLimit(deps.Limiter, "redirect", cfg.RateLimitRedirectPerMinute, cfg.RateLimitRedirectBurst)
+	// Регистрация и вход дорогие (bcrypt) и уязвимы к перебору паролей
+	authLimit := rateLimit(deps.Limiter, "auth", cfg.RateLimitAuthPerMinute, cfg.RateLimitAuthBurst)
+
+	r.With(createLimit).Post("/", handlers.PostHandler(store, cfg, urlShortener))
+	r.With(createLimit).Post("/api/shorten", handlers.PostAPIHandler(store, cfg, urlShortener))
+	r.With(createLimit).Post("/api/shorten/batch", handlers.PostAPIBatchHandler(store, cfg, urlShortener))
 	r.Get("/api/user/urls", handlers.GetAPIUserURLsHandler(store, cfg))
-	r.Delete("/api/user/urls", handlers.DeleteUserUrlsHandler(store))
-	r.Get("/*", handlers.GetHandler(store))
+	r.Delete("/api/user/urls", handlers.DeleteUserUrlsHandler(deps.Deleter))
+	r.Get("/api/user/quota", handlers.GetQuotaHandler(store, cfg))
+	r.Get("/api/user/urls/{short}/stats", handlers.GetURLStatsHandler(store))
+	r.With(createLimit).Patch("/api/user/urls/{short}", handlers.UpdateURLHandler(store, cfg))
+	r.Get("/api/user/urls/{short}/history", handlers.GetURLHistoryHandler(store))
+	r.With(createLimit).Post("/api/user/urls/{short}/rollback", handlers.RollbackURLHandler(store, cfg))
+	r.With(authLimit).Post("/api/user/register", handlers.RegisterHandler(store, deps.Tokens))
+	r.With(authLimit).Post("/api/user/login", handlers.LoginHandler(store, deps.Tokens))
+	r.Post("/api/user/logout", handlers.LogoutHandler(deps.Tokens))
+	if deps.OIDC != nil {
+		r.Get("/api/auth/oidc/login", handlers.OIDCLoginHandler(deps.OIDC, deps.Tokens))
+		r.Get("/api/auth/oidc/callback", handlers.OIDCCallbackHandler(deps.OIDC, deps.Tokens))
+	}
+	r.Post("/api/user/keys", handlers.CreateAPIKeyHandler(store))
+	r.Get("/api/user/keys", handlers.GetAPIKeysHandler(store))
+	r.Delete("/api/user/keys/{id}", handlers.RevokeAPIKeyHandler(store))
+	r.With(redirectLimit).Get("/*", handlers.GetHandler(store, cfg, deps.Clicks, deps.Metrics))
 	r.Get("/ping", handlers.PingHandler(store))
+	r.Get("/.well-known/jwks.json", handlers.JWKSHandler(deps.Tokens.Keys()))
 
 	r.MethodNotAllowed(methodNotAllowedHandler)
 
-	return r
+	if cfg.MetricsAddress != "" {
+		return r
+	}
+
+	// Метрики отдаются в обход middleware API, чтобы сборщик не получал куки и не попадал под лимиты
+	root := http.NewServeMux()
+	root.Handle("/metrics", deps.Metrics.Handler())
+	root.Handle("/", r)
+	return root
+}
+
+// rateLimit возвращает middleware с бюджетом perMinute запросов в минуту и burst подряд.
+// Без лимитера запросы не ограничиваются
+func rateLimit(limiter ratelimit.Limiter, class string, perMinute, burst int) func(http.Handler) http.Handler {
+	if limiter == nil {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	limit := ratelimit.Limit{PerMinute: perMinute, Burst: burst}
+	return internalMiddleware.TracedMiddleware("rate_limit", internalMiddleware.RateLimitMiddleware(limiter, class, limit))
 }
 
 func methodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
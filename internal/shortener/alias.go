@@ -0,0 +1,63 @@
+package shortener
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Ограничения на длину пользовательского алиаса
+const (
+	MinAliasLength = 3
+	MaxAliasLength = 32
+)
+
+var (
+	// ErrInvalidAlias алиас содержит недопустимые символы или имеет недопустимую длину
+	ErrInvalidAlias = fmt.Errorf("alias must be %d to %d characters long and contain only letters, digits, '-' and '_'", MinAliasLength, MaxAliasLength)
+	// ErrReservedAlias алиас совпадает с зарезервированным словом
+	ErrReservedAlias = errors.New("alias is reserved")
+)
+
+// reservedAliases пути, которые обслуживает сам сервис и которые нельзя занимать короткими URL
+var reservedAliases = map[string]bool{
+	"api":     true,
+	"ping":    true,
+	"metrics": true,
+	"health":  true,
+	"admin":   true,
+	"auth":    true,
+	"login":   true,
+	"logout":  true,
+}
+
+// ValidateAlias проверяет алфавит, длину и зарезервированность пользовательского алиаса
+func ValidateAlias(alias string) error {
+	if len(alias) < MinAliasLength || len(alias) > MaxAliasLength {
+		return ErrInvalidAlias
+	}
+
+	for _, c := range alias {
+		if !isAliasChar(c) {
+			return ErrInvalidAlias
+		}
+	}
+
+	if IsReserved(alias) {
+		return ErrReservedAlias
+	}
+
+	return nil
+}
+
+// IsReserved сообщает, совпадает ли короткий URL с зарезервированным словом
+func IsReserved(shortURL string) bool {
+	return reservedAliases[strings.ToLower(shortURL)]
+}
+
+func isAliasChar(c rune) bool {
+	return c >= 'a' && c <= 'z' ||
+		c >= 'A' && c <= 'Z' ||
+		c >= '0' && c <= '9' ||
+		c == '-' || c == '_'
+}
@@ -0,0 +1,37 @@
+package shortener
+
+import "strings"
+
+// CounterGenerator строит короткий URL из монотонно растущего счётчика в base62.
+// Коды короче length дополняются слева нулями
+type CounterGenerator struct {
+	counter blockCounter
+	length  int
+}
+
+func NewCounterGenerator(length int) *CounterGenerator {
+	return &CounterGenerator{length: length}
+}
+
+func (g *CounterGenerator) GenerateShortURL(string, int) string {
+	code := encodeBase62(g.counter.next(), base62Alphabet)
+	if len(code) < g.length {
+		code = strings.Repeat(string(base62Alphabet[0]), g.length-len(code)) + code
+	}
+	return code
+}
+
+// Position возвращает значение счётчика, из которого получен код. Коды короче
+// length и длиннее неё, но начинающиеся с нуля, генератор выдать не мог
+func (g *CounterGenerator) Position(shortURL string) (uint64, bool) {
+	if len(shortURL) < g.length || len(shortURL) > g.length && shortURL[0] == base62Alphabet[0] {
+		return 0, false
+	}
+	position, ok := decodeBase62(shortURL, base62Alphabet)
+	return position, ok && position > 0
+}
+
+// Use резервирует значения счётчика в seq
+func (g *CounterGenerator) Use(seq Sequence) error {
+	return g.counter.use(seq)
+}
@@ -0,0 +1,30 @@
+package shortener
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+)
+
+// HashGenerator строит короткий URL из префикса base64(sha256(url)).
+// При повторных попытках к URL добавляется номер попытки в качестве соли
+type HashGenerator struct {
+	length int
+}
+
+func NewHashGenerator(length int) *HashGenerator {
+	if length > base64.URLEncoding.EncodedLen(sha256.Size) {
+		length = base64.URLEncoding.EncodedLen(sha256.Size)
+	}
+	return &HashGenerator{length: length}
+}
+
+func (g *HashGenerator) GenerateShortURL(originalURL string, attempt int) string {
+	data := originalURL
+	if attempt > 0 {
+		data += "#" + strconv.Itoa(attempt)
+	}
+
+	hash := sha256.Sum256([]byte(data))
+	return base64.URLEncoding.EncodeToString(hash[:])[:g.length]
+}
@@ -0,0 +1,104 @@
+package shortener
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/big"
+	"math/bits"
+	"strings"
+)
+
+// Максимальная длина, при которой 62^length помещается в uint64
+const maxHashidsLength = 10
+
+// Простое число для перемешивания идентификаторов, взаимно простое с 62^length
+const hashidsPrime = 1580030173
+
+// HashidsGenerator строит короткий URL из счётчика, обфусцированного солью:
+// последовательные идентификаторы дают непохожие коды фиксированной длины
+type HashidsGenerator struct {
+	counter  blockCounter
+	alphabet string
+	length   int
+	space    uint64
+	offset   uint64
+	// inverse обратный к hashidsPrime по модулю space, восстанавливает идентификатор по коду
+	inverse uint64
+}
+
+func NewHashidsGenerator(salt string, length int) (*HashidsGenerator, error) {
+	if length > maxHashidsLength {
+		return nil, fmt.Errorf("hashids short URL length must not exceed %d", maxHashidsLength)
+	}
+
+	space := uint64(1)
+	for i := 0; i < length; i++ {
+		space *= 62
+	}
+
+	hasher := fnv.New64a()
+	hasher.Write([]byte(salt))
+
+	inverse := new(big.Int).ModInverse(big.NewInt(hashidsPrime), new(big.Int).SetUint64(space))
+
+	return &HashidsGenerator{
+		alphabet: consistentShuffle(base62Alphabet, salt),
+		length:   length,
+		space:    space,
+		offset:   hasher.Sum64() % space,
+		inverse:  inverse.Uint64(),
+	}, nil
+}
+
+func (g *HashidsGenerator) GenerateShortURL(string, int) string {
+	id := g.counter.next()
+
+	// (id * prime + offset) mod 62^length — биекция на пространстве кодов
+	n := (mulMod(id, hashidsPrime, g.space) + g.offset) % g.space
+
+	code := encodeBase62(n, g.alphabet)
+	if len(code) < g.length {
+		code = strings.Repeat(string(g.alphabet[0]), g.length-len(code)) + code
+	}
+	return code
+}
+
+// Position восстанавливает идентификатор, из которого получен код
+func (g *HashidsGenerator) Position(shortURL string) (uint64, bool) {
+	if len(shortURL) != g.length {
+		return 0, false
+	}
+	n, ok := decodeBase62(shortURL, g.alphabet)
+	if !ok || n >= g.space {
+		return 0, false
+	}
+	return mulMod((n+g.space-g.offset)%g.space, g.inverse, g.space), true
+}
+
+// Use резервирует значения счётчика в seq
+func (g *HashidsGenerator) Use(seq Sequence) error {
+	return g.counter.use(seq)
+}
+
+// mulMod вычисляет a * b mod m без переполнения
+func mulMod(a, b, m uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	_, rem := bits.Div64(hi%m, lo, m)
+	return rem
+}
+
+// consistentShuffle детерминированно перемешивает алфавит солью, как это делает Hashids
+func consistentShuffle(alphabet, salt string) string {
+	if salt == "" {
+		return alphabet
+	}
+
+	result := []byte(alphabet)
+	for i, v, p := len(result)-1, 0, 0; i > 0; i-- {
+		p += int(salt[v])
+		j := (int(salt[v]) + v + p) % i
+		result[i], result[j] = result[j], result[i]
+		v = (v + 1) % len(salt)
+	}
+	return string(result)
+}
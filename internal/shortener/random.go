@@ -0,0 +1,37 @@
+package shortener
+
+import (
+	"crypto/rand"
+)
+
+// RandomGenerator строит короткий URL из случайных символов base62
+type RandomGenerator struct {
+	length int
+}
+
+func NewRandomGenerator(length int) *RandomGenerator {
+	return &RandomGenerator{length: length}
+}
+
+func (g *RandomGenerator) GenerateShortURL(string, int) string {
+	shortURL := make([]byte, 0, g.length)
+	buf := make([]byte, g.length)
+
+	for len(shortURL) < g.length {
+		if _, err := rand.Read(buf); err != nil {
+			panic(err)
+		}
+		for _, b := range buf {
+			// Отбрасываем значения, которые нарушили бы равномерность распределения
+			if b >= 62*4 {
+				continue
+			}
+			shortURL = append(shortURL, base62Alphabet[b%62])
+			if len(shortURL) == g.length {
+				break
+			}
+		}
+	}
+
+	return string(shortURL)
+}
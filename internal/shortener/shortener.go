@@ -1,44 +1,176 @@
 package shortener
 
 import (
-	"crypto/sha256"
-	"encoding/base64"
-	"hash"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
 	"sync"
+
+	"github.com/learies/go-url-shortener/config"
+	"github.com/learies/go-url-shortener/internal/logger"
+	"github.com/learies/go-url-shortener/internal/store/storeerrors"
+)
+
+// Стратегии генерации коротких URL
+const (
+	StrategyHash    = "hash"
+	StrategyRandom  = "random"
+	StrategyCounter = "counter"
+	StrategyHashids = "hashids"
 )
 
-type URLShortener struct {
-	hasher   hash.Hash
-	hasherMu sync.Mutex
+const base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// MaxAttempts число попыток подобрать свободный короткий URL
+const MaxAttempts = 5
+
+// ErrAttemptsExhausted все попытки подобрать свободный короткий URL закончились коллизиями
+var ErrAttemptsExhausted = errors.New("failed to generate a free short URL")
+
+// Generator генерирует короткий идентификатор для оригинального URL.
+// attempt — номер попытки: при коллизии генератор должен вернуть другой код
+type Generator interface {
+	GenerateShortURL(originalURL string, attempt int) string
+}
+
+// Sequence постоянный счётчик, переживающий перезапуск сервиса
+type Sequence interface {
+	// Reserve увеличивает счётчик на size и возвращает его прежнее значение
+	Reserve(size uint64) (uint64, error)
+}
+
+// Sequential генератор, выдающий коды по счётчику. Значения счётчика резервируются
+// блоками в Sequence, поэтому после перезапуска уже выданные коды не повторяются
+type Sequential interface {
+	Generator
+	// Position возвращает значение счётчика, из которого получен код,
+	// или false, если генератор не мог выдать такой код
+	Position(shortURL string) (uint64, bool)
+	// Use резервирует в seq первый блок значений счётчика, следующие
+	// резервируются по мере расхода
+	Use(seq Sequence) error
+}
+
+// counterBlockSize число значений счётчика, резервируемых в Sequence за раз.
+// Неизрасходованный при остановке остаток блока пропускается
+const counterBlockSize = 1000
+
+// blockCounter счётчик, значения которого резервируются блоками в Sequence.
+// Пока Sequence не задан, счётчик живёт только в памяти процесса
+type blockCounter struct {
+	mu   sync.Mutex
+	seq  Sequence
+	last uint64
+	end  uint64
+}
+
+func (c *blockCounter) use(seq Sequence) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq = seq
+	return c.reserve()
 }
 
-func NewURLShortener() *URLShortener {
-	return &URLShortener{
-		hasher: sha256.New(),
+// next возвращает следующее значение счётчика. Если зарезервировать блок не удалось,
+// счётчик продолжается в памяти, а резервирование повторяется при следующем вызове
+func (c *blockCounter) next() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.seq != nil && c.last >= c.end {
+		if err := c.reserve(); err != nil {
+			logger.Log.Error("Failed to reserve short URL counter block", "error", err)
+		}
 	}
+	c.last++
+	return c.last
 }
 
-func (us *URLShortener) Get() hash.Hash {
-	us.hasherMu.Lock()
-	defer us.hasherMu.Unlock()
-	return us.hasher
+// reserve резервирует следующий блок значений. Вызывается под c.mu
+func (c *blockCounter) reserve() error {
+	start, err := c.seq.Reserve(counterBlockSize)
+	if err != nil {
+		return err
+	}
+	c.last = max(c.last, start)
+	c.end = start + counterBlockSize
+	return nil
 }
 
-func (us *URLShortener) Set(newHasher hash.Hash) {
-	us.hasherMu.Lock()
-	defer us.hasherMu.Unlock()
-	us.hasher = newHasher
+// NewGenerator создаёт генератор коротких URL по стратегии из конфигурации
+func NewGenerator(cfg config.Config) (Generator, error) {
+	if cfg.ShortURLLength <= 0 {
+		return nil, fmt.Errorf("invalid short URL length: %d", cfg.ShortURLLength)
+	}
+
+	switch cfg.ShortURLGenerator {
+	case StrategyHash, "":
+		return NewHashGenerator(cfg.ShortURLLength), nil
+	case StrategyRandom:
+		return NewRandomGenerator(cfg.ShortURLLength), nil
+	case StrategyCounter:
+		return NewCounterGenerator(cfg.ShortURLLength), nil
+	case StrategyHashids:
+		return NewHashidsGenerator(cfg.HashidsSalt, cfg.ShortURLLength)
+	default:
+		return nil, fmt.Errorf("unknown short URL generator: %q", cfg.ShortURLGenerator)
+	}
+}
+
+// Shorten генерирует короткий URL и сохраняет его через save, повторяя попытку
+// с новым кодом, если сгенерированный уже занят другим оригинальным URL
+func Shorten(gen Generator, originalURL string, save func(shortURL string) error) (string, error) {
+	for attempt := 0; attempt < MaxAttempts; attempt++ {
+		shortURL := gen.GenerateShortURL(originalURL, attempt)
+		if IsReserved(shortURL) {
+			continue
+		}
+
+		err := save(shortURL)
+		if errors.Is(err, storeerrors.ErrShortURLTaken) {
+			logger.Log.Warn("Short URL collision, retrying", "shortURL", shortURL, "attempt", attempt)
+			continue
+		}
+		return shortURL, err
+	}
+
+	return "", ErrAttemptsExhausted
 }
 
-func (us *URLShortener) GenerateShortURL(url string) string {
-	hasher := us.Get()
+// decodeBase62 декодирует строку в base62 с использованием заданного алфавита.
+// Возвращает false, если в строке есть символы вне алфавита или число не помещается в uint64
+func decodeBase62(s string, alphabet string) (uint64, bool) {
+	if s == "" {
+		return 0, false
+	}
 
-	hasher.Reset()
-	hasher.Write([]byte(url))
-	hash := hasher.Sum(nil)
-	shortURL := base64.URLEncoding.EncodeToString(hash)[:8]
+	var n uint64
+	for i := 0; i < len(s); i++ {
+		digit := strings.IndexByte(alphabet, s[i])
+		if digit < 0 {
+			return 0, false
+		}
+		hi, lo := bits.Mul64(n, 62)
+		if hi != 0 || lo+uint64(digit) < lo {
+			return 0, false
+		}
+		n = lo + uint64(digit)
+	}
+	return n, true
+}
 
-	us.Set(hasher)
+// encodeBase62 кодирует число в base62 с использованием заданного алфавита
+func encodeBase62(n uint64, alphabet string) string {
+	if n == 0 {
+		return string(alphabet[0])
+	}
 
-	return shortURL
+	var buf [11]byte
+	i := len(buf)
+	for n > 0 {
+		i--
+		buf[i] = alphabet[n%62]
+		n /= 62
+	}
+	return string(buf[i:])
 }
@@ -0,0 +1,175 @@
+package shortener
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/learies/go-url-shortener/config"
+	"github.com/learies/go-url-shortener/internal/logger"
+	"github.com/learies/go-url-shortener/internal/store/storeerrors"
+)
+
+func TestNewGenerator(t *testing.T) {
+	for _, strategy := range []string{StrategyHash, StrategyRandom, StrategyCounter, StrategyHashids} {
+		t.Run(strategy, func(t *testing.T) {
+			gen, err := NewGenerator(config.Config{ShortURLGenerator: strategy, ShortURLLength: 8})
+			require.NoError(t, err)
+			assert.NotEmpty(t, gen.GenerateShortURL("http://example.com", 0))
+		})
+	}
+
+	t.Run("unknown strategy", func(t *testing.T) {
+		_, err := NewGenerator(config.Config{ShortURLGenerator: "unknown", ShortURLLength: 8})
+		assert.Error(t, err)
+	})
+}
+
+func TestHashGenerator(t *testing.T) {
+	gen := NewHashGenerator(8)
+
+	shortURL := gen.GenerateShortURL("http://example.com", 0)
+	assert.Len(t, shortURL, 8)
+	assert.Equal(t, shortURL, gen.GenerateShortURL("http://example.com", 0))
+	assert.NotEqual(t, shortURL, gen.GenerateShortURL("http://example.org", 0))
+	assert.NotEqual(t, shortURL, gen.GenerateShortURL("http://example.com", 1))
+}
+
+func TestRandomGenerator(t *testing.T) {
+	gen := NewRandomGenerator(8)
+
+	shortURL := gen.GenerateShortURL("http://example.com", 0)
+	assert.Len(t, shortURL, 8)
+	assert.NotEqual(t, shortURL, gen.GenerateShortURL("http://example.com", 0))
+}
+
+// memorySequence постоянный счётчик для тестов
+type memorySequence struct {
+	value uint64
+}
+
+func (seq *memorySequence) Reserve(size uint64) (uint64, error) {
+	value := seq.value
+	seq.value += size
+	return value, nil
+}
+
+func TestCounterGenerator(t *testing.T) {
+	gen := NewCounterGenerator(1)
+	require.NoError(t, gen.Use(&memorySequence{value: 59}))
+
+	assert.Equal(t, "y", gen.GenerateShortURL("", 0))
+	assert.Equal(t, "z", gen.GenerateShortURL("", 0))
+	assert.Equal(t, "10", gen.GenerateShortURL("", 0))
+
+	padded := NewCounterGenerator(4)
+	shortURL := padded.GenerateShortURL("", 0)
+	assert.Equal(t, "0001", shortURL)
+
+	position, ok := padded.Position(shortURL)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1), position)
+	// Такие коды генератор выдать не мог
+	for _, code := range []string{"spring-sale", "01", "00001", "0000"} {
+		_, ok = padded.Position(code)
+		assert.False(t, ok, code)
+	}
+
+	// После перезапуска счётчик продолжается со следующего зарезервированного блока
+	seq := &memorySequence{}
+	first := NewCounterGenerator(4)
+	require.NoError(t, first.Use(seq))
+	for i := 0; i < counterBlockSize+1; i++ {
+		first.GenerateShortURL("", 0)
+	}
+	assert.Equal(t, uint64(2*counterBlockSize), seq.value)
+
+	restarted := NewCounterGenerator(4)
+	require.NoError(t, restarted.Use(seq))
+	position, ok = restarted.Position(restarted.GenerateShortURL("", 0))
+	assert.True(t, ok)
+	assert.Equal(t, uint64(2*counterBlockSize+1), position)
+}
+
+func TestHashidsGenerator(t *testing.T) {
+	gen, err := NewHashidsGenerator("salt", 6)
+	require.NoError(t, err)
+
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		shortURL := gen.GenerateShortURL("", 0)
+		assert.Len(t, shortURL, 6)
+		assert.False(t, seen[shortURL], "duplicate short URL %s", shortURL)
+		seen[shortURL] = true
+	}
+
+	other, err := NewHashidsGenerator("pepper", 6)
+	require.NoError(t, err)
+	first, _ := NewHashidsGenerator("salt", 6)
+	assert.NotEqual(t, first.GenerateShortURL("", 0), other.GenerateShortURL("", 0))
+
+	resumed, err := NewHashidsGenerator("salt", 6)
+	require.NoError(t, err)
+	var codes []string
+	for i := 0; i < 3; i++ {
+		codes = append(codes, resumed.GenerateShortURL("", 0))
+	}
+	position, ok := resumed.Position(codes[2])
+	assert.True(t, ok)
+	assert.Equal(t, uint64(3), position)
+
+	// После перезапуска счётчик продолжается с сохранённой позиции
+	restarted, err := NewHashidsGenerator("salt", 6)
+	require.NoError(t, err)
+	require.NoError(t, restarted.Use(&memorySequence{value: position}))
+	next := restarted.GenerateShortURL("", 0)
+	assert.NotContains(t, codes, next)
+	assert.Equal(t, resumed.GenerateShortURL("", 0), next)
+
+	_, err = NewHashidsGenerator("salt", maxHashidsLength+1)
+	assert.Error(t, err)
+}
+
+func TestShorten(t *testing.T) {
+	require.NoError(t, logger.Initialize("error"))
+	gen := NewHashGenerator(8)
+
+	t.Run("retries on collision", func(t *testing.T) {
+		taken := gen.GenerateShortURL("http://example.com", 0)
+
+		shortURL, err := Shorten(gen, "http://example.com", func(shortURL string) error {
+			if shortURL == taken {
+				return storeerrors.ErrShortURLTaken
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, gen.GenerateShortURL("http://example.com", 1), shortURL)
+	})
+
+	t.Run("returns other errors", func(t *testing.T) {
+		_, err := Shorten(gen, "http://example.com", func(string) error {
+			return storeerrors.ErrURLExists
+		})
+		assert.ErrorIs(t, err, storeerrors.ErrURLExists)
+	})
+
+	t.Run("keeps existing short URL of conflict", func(t *testing.T) {
+		_, err := Shorten(gen, "http://example.com", func(string) error {
+			return &storeerrors.ErrConflict{ShortURL: "existing"}
+		})
+		assert.ErrorIs(t, err, storeerrors.ErrURLExists)
+
+		var conflict *storeerrors.ErrConflict
+		require.ErrorAs(t, err, &conflict)
+		assert.Equal(t, "existing", conflict.ShortURL)
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		_, err := Shorten(gen, "http://example.com", func(string) error {
+			return storeerrors.ErrShortURLTaken
+		})
+		assert.ErrorIs(t, err, ErrAttemptsExhausted)
+	})
+}
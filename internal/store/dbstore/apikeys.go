@@ -0,0 +1,92 @@
+package dbstore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/learies/go-url-shortener/internal/models"
+	"github.com/learies/go-url-shortener/internal/store/storeerrors"
+)
+
+// CreateAPIKey сохраняет новый ключ доступа пользователя
+func (ds *DBStore) CreateAPIKey(ctx context.Context, key models.APIKey) error {
+	query := `
+	INSERT INTO api_keys (id, user_id, name, prefix, key_hash, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err := ds.DB.ExecContext(ctx, query, key.ID, key.UserID, key.Name, key.Prefix, key.KeyHash, key.CreatedAt)
+	return err
+}
+
+// GetUserAPIKeys возвращает неотозванные ключи доступа пользователя в порядке создания
+func (ds *DBStore) GetUserAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error) {
+	query := `
+	SELECT id, user_id, name, prefix, key_hash, created_at
+	FROM api_keys
+	WHERE user_id = $1 AND revoked_at IS NULL
+	ORDER BY created_at`
+
+	rows, err := ds.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		var key models.APIKey
+		if err := rows.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.KeyHash, &key.CreatedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// GetAPIKey ищет неотозванный ключ по хэшу
+func (ds *DBStore) GetAPIKey(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	query := `
+	SELECT id, user_id, name, prefix, key_hash, created_at
+	FROM api_keys
+	WHERE key_hash = $1 AND revoked_at IS NULL`
+
+	var key models.APIKey
+	err := ds.DB.QueryRowContext(ctx, query, keyHash).
+		Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.KeyHash, &key.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, storeerrors.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// RevokeAPIKey отзывает ключ пользователя
+func (ds *DBStore) RevokeAPIKey(ctx context.Context, userID, keyID string) error {
+	query := `
+	UPDATE api_keys SET revoked_at = now()
+	WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+
+	result, err := ds.DB.ExecContext(ctx, query, keyID, userID)
+	if err != nil {
+		return err
+	}
+	revoked, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if revoked == 0 {
+		return storeerrors.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// ClaimUserAPIKeys передаёт ключи доступа пользователя fromUserID пользователю toUserID
+func (ds *DBStore) ClaimUserAPIKeys(ctx context.Context, fromUserID, toUserID string) (int64, error) {
+	result, err := ds.DB.ExecContext(ctx, "UPDATE api_keys SET user_id = $2 WHERE user_id = $1", fromUserID, toUserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -3,28 +3,76 @@ package dbstore
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"time"
 
 	"github.com/google/uuid"
-	"github.com/learies/go-url-shortener/internal/logger"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/learies/go-url-shortener/internal/contextutils"
 	"github.com/learies/go-url-shortener/internal/models"
+	"github.com/learies/go-url-shortener/internal/store/storeerrors"
 )
 
+// Код ошибки PostgreSQL при нарушении ограничения уникальности
+const uniqueViolationCode = "23505"
+
 // DBStore хранение URL в базе данных
 type DBStore struct {
 	DB *sql.DB
 }
 
-// Set сохраняет URL в базу данных
-func (ds *DBStore) Set(ctx context.Context, shortURL, originalURL, userID string) error {
+// Set сохраняет URL в базу данных.
+// Если оригинальный URL уже сокращён, возвращает *storeerrors.ErrConflict с сохранённым коротким URL
+func (ds *DBStore) Set(ctx context.Context, url models.Storage) error {
+	id := uuid.New()
+
+	if err := purgeExpired(ctx, ds.DB, url.OriginalURL); err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO urls (id, short_url, original_url, user_id, expires_at, redirect_type)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT DO NOTHING`
+
+	result, err := ds.DB.ExecContext(ctx, query, id, url.ShortURL, url.OriginalURL, url.UserID, url.ExpiresAt, url.RedirectType)
+	if err != nil {
+		return err
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if inserted > 0 {
+		return nil
+	}
+
+	existingShortURL, err := findConflict(ctx, ds.DB, url.OriginalURL)
+	if err != nil {
+		return err
+	}
+	if existingShortURL == "" {
+		return storeerrors.ErrShortURLTaken
+	}
+	return &storeerrors.ErrConflict{ShortURL: existingShortURL}
+}
+
+// SetAlias сохраняет URL под пользовательским алиасом.
+// Если алиас уже занят, возвращает storeerrors.ErrAliasTaken
+func (ds *DBStore) SetAlias(ctx context.Context, url models.Storage) error {
 	id := uuid.New()
 
 	query := `
-	INSERT INTO urls (id, short_url, original_url, user_id)
-	VALUES ($1, $2, $3, $4)`
-	// ON CONFLICT (short_url) DO UPDATE SET original_url = EXCLUDED.original_url;`
+	INSERT INTO urls (id, short_url, original_url, user_id, expires_at, redirect_type, is_alias)
+	VALUES ($1, $2, $3, $4, $5, $6, TRUE)`
 
-	_, err := ds.DB.ExecContext(ctx, query, id, shortURL, originalURL, userID)
+	_, err := ds.DB.ExecContext(ctx, query, id, url.ShortURL, url.OriginalURL, url.UserID, url.ExpiresAt, url.RedirectType)
 	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+			return storeerrors.ErrAliasTaken
+		}
 		return err
 	}
 	return nil
@@ -33,48 +81,127 @@ func (ds *DBStore) Set(ctx context.Context, shortURL, originalURL, userID string
 // Get получает URL из базы данных если is_deleted = false
 func (ds *DBStore) Get(ctx context.Context, shortURL string) (*models.Storage, bool) {
 	var s models.Storage
-	err := ds.DB.QueryRowContext(ctx, "SELECT id, short_url, original_url, user_id, is_deleted FROM urls WHERE short_url = $1", shortURL).Scan(
-		&s.ID, &s.ShortURL, &s.OriginalURL, &s.UserID, &s.DeletedFlag,
+	err := ds.DB.QueryRowContext(ctx, "SELECT id, short_url, original_url, user_id, is_deleted, expires_at, redirect_type FROM urls WHERE short_url = $1", shortURL).Scan(
+		&s.ID, &s.ShortURL, &s.OriginalURL, &s.UserID, &s.DeletedFlag, &s.ExpiresAt, &s.RedirectType,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, false
 		}
-		logger.Log.Error("Failed to get URL mapping from database", "error", err)
+		contextutils.Logger(ctx).Error("Failed to get URL mapping from database", "error", err)
 		return nil, false
 	}
 
 	return &s, true
 }
 
-// SetBatch сохраняет пакет URL в базе данных
-func (ds *DBStore) SetBatch(ctx context.Context, urls []models.BatchURLWrite) {
-	tx, err := ds.DB.Begin()
+// SetBatch сохраняет пакет URL в базе данных в одной транзакции.
+// Конфликты уникальности не прерывают транзакцию: элемент получает статус
+// exists или taken, а в режиме atomic транзакция в конце откатывается
+func (ds *DBStore) SetBatch(ctx context.Context, urls []models.BatchURLWrite, atomic bool) ([]models.BatchURLResult, error) {
+	tx, err := ds.DB.BeginTx(ctx, nil)
 	if err != nil {
-		logger.Log.Error("Failed to start transaction", "error", err)
-		return
+		return nil, err
 	}
+	defer tx.Rollback()
 
-	stmt, err := tx.PrepareContext(ctx, "INSERT INTO urls (id, short_url, original_url, user_id) VALUES ($1, $2, $3, $4)")
+	stmt, err := tx.PrepareContext(ctx, `
+	INSERT INTO urls (id, short_url, original_url, user_id, expires_at)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT DO NOTHING`)
 	if err != nil {
-		logger.Log.Error("Failed to prepare statement", "error", err)
-		tx.Rollback()
-		return
+		return nil, err
 	}
 	defer stmt.Close()
 
-	for _, url := range urls {
-		_, err := stmt.ExecContext(ctx, url.CorrelationID, url.ShortURL, url.OriginalURL, url.UserID)
+	results := make([]models.BatchURLResult, len(urls))
+	failed := false
+	for i, url := range urls {
+		results[i] = models.BatchURLResult{CorrelationID: url.CorrelationID, ShortURL: url.ShortURL, Status: models.BatchStatusCreated}
+
+		if err := purgeExpired(ctx, tx, url.OriginalURL); err != nil {
+			return nil, err
+		}
+
+		result, err := stmt.ExecContext(ctx, uuid.New(), url.ShortURL, url.OriginalURL, url.UserID, url.ExpiresAt)
+		if err != nil {
+			return nil, err
+		}
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if inserted > 0 {
+			continue
+		}
+
+		failed = true
+		existingShortURL, err := findConflict(ctx, tx, url.OriginalURL)
 		if err != nil {
-			logger.Log.Error("Failed to insert URL", "error", err)
-			tx.Rollback()
-			logger.Log.Info("Transaction rolled back")
+			return nil, err
+		}
+		if existingShortURL == "" {
+			results[i].Status = models.BatchStatusTaken
+		} else {
+			results[i].Status = models.BatchStatusExists
+			results[i].ShortURL = existingShortURL
 		}
 	}
-	err = tx.Commit()
-	if err != nil {
-		logger.Log.Error("Failed to commit transaction", "error", err)
+
+	if atomic && failed {
+		return results, storeerrors.ErrBatchRolledBack
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// queryRower выполняет запрос, возвращающий одну строку: *sql.DB или *sql.Tx
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// execer выполняет запрос без результата: *sql.DB или *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// purgeExpired удаляет истёкшие сгенерированные URL с оригинальным URL originalURL.
+// Они всё равно удалятся при очистке, а до неё мешали бы сократить URL заново:
+// удалённые пользователем URL уникальный индекс не учитывает, истёкшие — учитывает
+func purgeExpired(ctx context.Context, e execer, originalURL string) error {
+	_, err := e.ExecContext(ctx, "DELETE FROM urls WHERE original_url = $1 AND NOT is_alias AND expires_at <= now()", originalURL)
+	return err
+}
+
+// findConflict ищет действующую (не удалённую и не истёкшую) запись, с которой
+// конфликтует вставка URL. Возвращает короткий URL, под которым оригинальный URL
+// уже сокращён, или пустую строку, если короткий URL занят
+func findConflict(ctx context.Context, q queryRower, originalURL string) (string, error) {
+	var existingShortURL string
+	err := q.QueryRowContext(ctx, `
+	SELECT short_url FROM urls
+	WHERE original_url = $1 AND NOT is_alias
+	  AND NOT is_deleted AND (expires_at IS NULL OR expires_at > now())`, originalURL).Scan(&existingShortURL)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return existingShortURL, err
+}
+
+// FindShortURL возвращает сгенерированный короткий URL, под которым уже сокращён оригинальный URL
+func (ds *DBStore) FindShortURL(ctx context.Context, originalURL string) (string, error) {
+	var shortURL string
+	err := ds.DB.QueryRowContext(ctx, `
+	SELECT short_url FROM urls
+	WHERE original_url = $1 AND NOT is_alias
+	  AND NOT is_deleted AND (expires_at IS NULL OR expires_at > now())`, originalURL).Scan(&shortURL)
+	if err == sql.ErrNoRows {
+		return "", nil
 	}
+	return shortURL, err
 }
 
 // GetBatch получает пакет URL из базы данных
@@ -83,7 +210,7 @@ func (ds *DBStore) GetUserUrls(ctx context.Context, userID string) ([]models.URL
 
 	rows, err := ds.DB.QueryContext(ctx, "SELECT short_url, original_url FROM urls WHERE user_id = $1", userID)
 	if err != nil {
-		logger.Log.Error("Failed to get user URLs from database", "error", err)
+		contextutils.Logger(ctx).Error("Failed to get user URLs from database", "error", err)
 		return nil, false
 	}
 	defer rows.Close()
@@ -92,7 +219,7 @@ func (ds *DBStore) GetUserUrls(ctx context.Context, userID string) ([]models.URL
 		var url models.URL
 		err := rows.Scan(&url.ShortURL, &url.OriginalURL)
 		if err != nil {
-			logger.Log.Error("Failed to scan user URLs from database", "error", err)
+			contextutils.Logger(ctx).Error("Failed to scan user URLs from database", "error", err)
 			return nil, false
 		}
 		urls = append(urls, url)
@@ -100,44 +227,152 @@ func (ds *DBStore) GetUserUrls(ctx context.Context, userID string) ([]models.URL
 
 	// Check for any error after closing the loop
 	if err = rows.Err(); err != nil {
-		logger.Log.Error("Failed during rows iteration", "error", err)
+		contextutils.Logger(ctx).Error("Failed during rows iteration", "error", err)
 		return nil, false
 	}
 
 	return urls, true
 }
 
+// EachGeneratedShortURL вызывает fn для каждого сгенерированного короткого URL
+func (ds *DBStore) EachGeneratedShortURL(ctx context.Context, fn func(shortURL string)) error {
+	rows, err := ds.DB.QueryContext(ctx, "SELECT short_url FROM urls WHERE NOT is_alias")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var shortURL string
+		if err := rows.Scan(&shortURL); err != nil {
+			return err
+		}
+		fn(shortURL)
+	}
+	return rows.Err()
+}
+
+// CountUserUrls возвращает число действующих URL пользователя
+func (ds *DBStore) CountUserUrls(ctx context.Context, userID string) (int, error) {
+	query := `
+	SELECT count(*)
+	FROM urls
+	WHERE user_id = $1 AND NOT is_deleted AND (expires_at IS NULL OR expires_at > now())`
+
+	var count int
+	err := ds.DB.QueryRowContext(ctx, query, userID).Scan(&count)
+	return count, err
+}
+
 // DeleteUserUrls устанавливает флаг is_deleted в true для URL, принадлежащих пользователю.
-func (ds *DBStore) DeleteUserUrls(ctx context.Context, deleteUserURLs <-chan models.UserURL) {
-	tx, err := ds.DB.Begin()
+// Пары (user_id, short_url) помечаются одним запросом UPDATE
+func (ds *DBStore) DeleteUserUrls(ctx context.Context, userURLs []models.UserURL) error {
+	if len(userURLs) == 0 {
+		return nil
+	}
+
+	// Пары передаются двумя массивами, чтобы число параметров не зависело от размера пачки
+	userIDs := make([]string, len(userURLs))
+	shortURLs := make([]string, len(userURLs))
+	for i, userURL := range userURLs {
+		userIDs[i] = userURL.UserID
+		shortURLs[i] = userURL.ShortURL
+	}
+
+	_, err := ds.DB.ExecContext(ctx, `
+	UPDATE urls SET is_deleted = true
+	FROM unnest($1::text[], $2::text[]) AS d (user_id, short_url)
+	WHERE NOT urls.is_deleted AND urls.short_url = d.short_url AND urls.user_id = d.user_id::uuid`,
+		userIDs, shortURLs)
+	return err
+}
+
+// DeleteExpired удаляет URL, срок действия которых истёк раньше before
+func (ds *DBStore) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	result, err := ds.DB.ExecContext(ctx, "DELETE FROM urls WHERE expires_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// SaveClicks сохраняет пачку событий перехода в одной транзакции.
+// Переходы по URL, удалённым до сохранения пачки, пропускаются, чтобы
+// нарушение внешнего ключа не откатывало остальные
+func (ds *DBStore) SaveClicks(ctx context.Context, clicks []models.Click) error {
+	tx, err := ds.DB.BeginTx(ctx, nil)
 	if err != nil {
-		logger.Log.Error("Failed to start transaction", "error", err)
-		return
+		return err
 	}
+	defer tx.Rollback()
 
-	stmt, err := tx.PrepareContext(ctx, "UPDATE urls SET is_deleted = true WHERE user_id = $1 AND short_url = $2")
+	stmt, err := tx.PrepareContext(ctx, `
+	INSERT INTO clicks (short_url, clicked_at, referrer, user_agent, ip)
+	SELECT $1::varchar, $2::timestamptz, $3::text, $4::text, $5::text
+	WHERE EXISTS (SELECT 1 FROM urls WHERE short_url = $1)`)
 	if err != nil {
-		logger.Log.Error("Failed to prepare statement", "error", err)
-		tx.Rollback()
-		return
+		return err
 	}
 	defer stmt.Close()
 
-	for userURL := range deleteUserURLs {
-		_, err := stmt.ExecContext(ctx, userURL.UserID, userURL.ShortURL)
+	for _, click := range clicks {
+		_, err := stmt.ExecContext(ctx, click.ShortURL, click.Timestamp, click.Referrer, click.UserAgent, click.IP)
 		if err != nil {
-			logger.Log.Error("Failed to delete URL", "error", err)
-			tx.Rollback()
-			logger.Log.Info("Transaction rolled back")
+			return err
 		}
 	}
-	err = tx.Commit()
+
+	return tx.Commit()
+}
+
+// GetClickStats получает статистику переходов по короткому URL
+func (ds *DBStore) GetClickStats(ctx context.Context, shortURL string) (*models.ClickStats, error) {
+	stats := &models.ClickStats{
+		ShortURL: shortURL,
+		Daily:    []models.DailyClicks{},
+	}
+
+	err := ds.DB.QueryRowContext(ctx, "SELECT COUNT(*), COUNT(DISTINCT ip) FROM clicks WHERE short_url = $1", shortURL).Scan(
+		&stats.TotalClicks, &stats.UniqueVisitors,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := ds.DB.QueryContext(ctx, `
+	SELECT (clicked_at AT TIME ZONE 'UTC')::date AS day, COUNT(*), COUNT(DISTINCT ip)
+	FROM clicks
+	WHERE short_url = $1
+	GROUP BY day
+	ORDER BY day`, shortURL)
 	if err != nil {
-		logger.Log.Error("Failed to commit transaction", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var day time.Time
+		var daily models.DailyClicks
+		if err := rows.Scan(&day, &daily.Clicks, &daily.UniqueVisitors); err != nil {
+			return nil, err
+		}
+		daily.Date = day.Format("2006-01-02")
+		stats.Daily = append(stats.Daily, daily)
 	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
 }
 
 // Ping проверяет доступность базы данных
 func (ds *DBStore) Ping() error {
 	return ds.DB.Ping()
 }
+
+// Close закрывает пул соединений с базой данных
+func (ds *DBStore) Close() error {
+	return ds.DB.Close()
+}
@@ -0,0 +1,89 @@
+package dbstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/learies/go-url-shortener/internal/models"
+	"github.com/learies/go-url-shortener/internal/store/storeerrors"
+)
+
+// UpdateOriginalURL меняет оригинальный URL пользователя и записывает изменение
+// в историю в одной транзакции. Если новый оригинальный URL уже сокращён,
+// возвращает *storeerrors.ErrConflict с сохранённым коротким URL
+func (ds *DBStore) UpdateOriginalURL(ctx context.Context, change models.URLChange) (*models.URLChange, error) {
+	tx, err := ds.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+	SELECT original_url FROM urls
+	WHERE short_url = $1 AND user_id = $2 AND NOT is_deleted
+	FOR UPDATE`, change.ShortURL, change.ChangedBy).Scan(&change.OldURL)
+	if err == sql.ErrNoRows {
+		return nil, storeerrors.ErrURLNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := purgeExpired(ctx, tx, change.NewURL); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE urls SET original_url = $2 WHERE short_url = $1", change.ShortURL, change.NewURL)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if !errors.As(err, &pgErr) || pgErr.Code != uniqueViolationCode {
+			return nil, err
+		}
+		// Транзакция после ошибки прервана, сохранённый URL ищем вне её
+		tx.Rollback()
+		existingShortURL, err := findConflict(ctx, ds.DB, change.NewURL)
+		if err != nil {
+			return nil, err
+		}
+		return nil, &storeerrors.ErrConflict{ShortURL: existingShortURL}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO url_history (id, short_url, old_url, new_url, changed_by, changed_at)
+	VALUES ($1, $2, $3, $4, $5, $6)`,
+		change.ID, change.ShortURL, change.OldURL, change.NewURL, change.ChangedBy, change.ChangedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &change, nil
+}
+
+// GetURLHistory возвращает историю изменений оригинального URL в порядке их внесения
+func (ds *DBStore) GetURLHistory(ctx context.Context, shortURL string) ([]models.URLChange, error) {
+	rows, err := ds.DB.QueryContext(ctx, `
+	SELECT id, short_url, old_url, new_url, changed_by, changed_at
+	FROM url_history
+	WHERE short_url = $1
+	ORDER BY changed_at, id`, shortURL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []models.URLChange{}
+	for rows.Next() {
+		var change models.URLChange
+		if err := rows.Scan(&change.ID, &change.ShortURL, &change.OldURL, &change.NewURL, &change.ChangedBy, &change.ChangedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, change)
+	}
+
+	return history, rows.Err()
+}
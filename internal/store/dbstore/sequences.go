@@ -0,0 +1,16 @@
+package dbstore
+
+import "context"
+
+// ReserveSequence увеличивает счётчик name на size одним запросом и возвращает его прежнее значение
+func (ds *DBStore) ReserveSequence(ctx context.Context, name string, size uint64) (uint64, error) {
+	var value int64
+	err := ds.DB.QueryRowContext(ctx, `
+	INSERT INTO sequences (name, value) VALUES ($1, $2)
+	ON CONFLICT (name) DO UPDATE SET value = sequences.value + EXCLUDED.value
+	RETURNING value`, name, int64(size)).Scan(&value)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(value) - size, nil
+}
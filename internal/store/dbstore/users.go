@@ -0,0 +1,59 @@
+package dbstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/learies/go-url-shortener/internal/models"
+	"github.com/learies/go-url-shortener/internal/store/storeerrors"
+)
+
+// CreateUser регистрирует пользователя
+func (ds *DBStore) CreateUser(ctx context.Context, user models.User) error {
+	query := `
+	INSERT INTO users (id, login, password_hash, created_at)
+	VALUES ($1, $2, $3, $4)`
+
+	_, err := ds.DB.ExecContext(ctx, query, user.ID, user.Login, user.PasswordHash, user.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+			return storeerrors.ErrLoginTaken
+		}
+		return err
+	}
+	return nil
+}
+
+// GetUserByLogin ищет пользователя по логину
+func (ds *DBStore) GetUserByLogin(ctx context.Context, login string) (*models.User, error) {
+	return ds.getUser(ctx, "SELECT id, login, password_hash, created_at FROM users WHERE login = $1", login)
+}
+
+// GetUser ищет пользователя по ID
+func (ds *DBStore) GetUser(ctx context.Context, userID string) (*models.User, error) {
+	return ds.getUser(ctx, "SELECT id, login, password_hash, created_at FROM users WHERE id = $1", userID)
+}
+
+func (ds *DBStore) getUser(ctx context.Context, query string, arg string) (*models.User, error) {
+	var user models.User
+	err := ds.DB.QueryRowContext(ctx, query, arg).Scan(&user.ID, &user.Login, &user.PasswordHash, &user.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, storeerrors.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ClaimUserUrls передаёт URL пользователя fromUserID пользователю toUserID
+func (ds *DBStore) ClaimUserUrls(ctx context.Context, fromUserID, toUserID string) (int64, error) {
+	result, err := ds.DB.ExecContext(ctx, "UPDATE urls SET user_id = $2 WHERE user_id = $1", fromUserID, toUserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
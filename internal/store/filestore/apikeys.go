@@ -0,0 +1,148 @@
+package filestore
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/learies/go-url-shortener/internal/models"
+	"github.com/learies/go-url-shortener/internal/store/storeerrors"
+)
+
+// apiKeysFilePath путь к файлу с ключами доступа рядом с основным файлом хранилища.
+// Каждая строка — полное состояние ключа, при загрузке побеждает последняя
+func (store *FileStore) apiKeysFilePath() string {
+	return store.filePath + ".apikeys"
+}
+
+// CreateAPIKey сохраняет новый ключ доступа пользователя
+func (store *FileStore) CreateAPIKey(ctx context.Context, key models.APIKey) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return store.writeAPIKey(key)
+}
+
+// GetUserAPIKeys возвращает неотозванные ключи доступа пользователя в порядке создания
+func (store *FileStore) GetUserAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	keys := []models.APIKey{}
+	for _, key := range store.apiKeys {
+		if key.UserID == userID && key.RevokedAt == nil {
+			keys = append(keys, *key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+// GetAPIKey ищет неотозванный ключ по хэшу
+func (store *FileStore) GetAPIKey(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	id, exists := store.apiKeyIDs[keyHash]
+	if !exists || store.apiKeys[id].RevokedAt != nil {
+		return nil, storeerrors.ErrAPIKeyNotFound
+	}
+	found := *store.apiKeys[id]
+	return &found, nil
+}
+
+// RevokeAPIKey отзывает ключ пользователя и дописывает его новое состояние в файл
+func (store *FileStore) RevokeAPIKey(ctx context.Context, userID, keyID string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	key, exists := store.apiKeys[keyID]
+	if !exists || key.UserID != userID || key.RevokedAt != nil {
+		return storeerrors.ErrAPIKeyNotFound
+	}
+
+	revoked := *key
+	revokedAt := time.Now()
+	revoked.RevokedAt = &revokedAt
+	return store.writeAPIKey(revoked)
+}
+
+// ClaimUserAPIKeys передаёт ключи доступа пользователя fromUserID пользователю toUserID
+func (store *FileStore) ClaimUserAPIKeys(ctx context.Context, fromUserID, toUserID string) (int64, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	var claimed []models.APIKey
+	for _, key := range store.apiKeys {
+		if key.UserID == fromUserID {
+			claimed = append(claimed, *key)
+		}
+	}
+
+	for i, key := range claimed {
+		key.UserID = toUserID
+		if err := store.writeAPIKey(key); err != nil {
+			return int64(i), err
+		}
+	}
+	return int64(len(claimed)), nil
+}
+
+// writeAPIKey дописывает состояние ключа в файл и обновляет его в памяти.
+// Вызывается под store.mu
+func (store *FileStore) writeAPIKey(key models.APIKey) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(store.apiKeysFilePath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+
+	store.putAPIKey(key)
+	return nil
+}
+
+// putAPIKey обновляет ключ в памяти вместе с индексом apiKeyIDs по хэшу,
+// который заменяет уникальный индекс key_hash в базе данных
+func (store *FileStore) putAPIKey(key models.APIKey) {
+	store.apiKeys[key.ID] = &key
+	store.apiKeyIDs[key.KeyHash] = key.ID
+}
+
+// loadAPIKeys загружает ключи доступа из файла
+func (store *FileStore) loadAPIKeys() error {
+	file, err := os.Open(store.apiKeysFilePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	for {
+		var key models.APIKey
+		if err := decoder.Decode(&key); err != nil {
+			break
+		}
+		store.putAPIKey(key)
+	}
+
+	return nil
+}
@@ -1,137 +1,818 @@
 package filestore
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"os"
+	"path/filepath"
 	"sync"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/learies/go-url-shortener/internal/analytics"
+	"github.com/learies/go-url-shortener/internal/contextutils"
 	"github.com/learies/go-url-shortener/internal/logger"
 	"github.com/learies/go-url-shortener/internal/models"
+	"github.com/learies/go-url-shortener/internal/store/storeerrors"
+	"github.com/learies/go-url-shortener/internal/store/urlindex"
 )
 
-// URLStore хранение URL в файле
+// Операции журнала
+const (
+	opCreate = "create"
+	opUpdate = "update"
+	opDelete = "delete"
+	opPurge  = "purge"
+)
+
+// Журнал сжимается, когда в нём накопилось не меньше compactMinRecords записей
+// и их больше чем в compactRatio раз превышает число живых URL
+const (
+	compactMinRecords = 1000
+	compactRatio      = 2
+)
+
+// record запись журнала файлового хранилища.
+// Запись без op — строка старого формата или снимка, равносильна созданию URL
+type record struct {
+	Op string `json:"op,omitempty"`
+	models.Storage
+}
+
+// FileStore хранение URL в append-only журнале.
+// Журнал один раз воспроизводится в память при старте, дальше все чтения идут
+// из памяти, а изменения дописываются в конец файла и сбрасываются на диск
+// пачкой. Периодически и по росту журнала он сжимается в снимок живых URL,
+// который атомарно подменяет файл через rename
 type FileStore struct {
-	URLMapping map[string]string
-	FilePath   string
-	Storage    models.Storage
-	mu         sync.Mutex
+	index *urlindex.Index
+	// clicks статистика переходов по коротким URL, clickRecords — число строк в файле переходов
+	clicks       map[string]*analytics.Counter
+	clickRecords int
+	history      map[string][]models.URLChange
+	apiKeys      map[string]*models.APIKey
+	apiKeyIDs    map[string]string
+	users        map[string]*models.User
+	sequences    map[string]uint64
+	filePath     string
+	file         *os.File
+	records      int
+	mu           sync.RWMutex
+
+	// Пока идёт сжатие (compacting), новые записи журнала и события переходов
+	// копятся в tail и clickTail, чтобы дописать их в сжатые файлы перед подменой.
+	// clickRewrites считает перезаписи файла переходов в обход сжатия.
+	// compactMu не даёт двум сжатиям идти одновременно
+	compactMu     sync.Mutex
+	compacting    bool
+	tail          []record
+	clickTail     []models.Click
+	clickRewrites int
+	trigger       chan struct{}
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
 }
 
-// URLMapping структура для JSON
-type URLMapping struct {
-	ShortURL    string `json:"short_url"`
-	OriginalURL string `json:"original_url"`
+// NewFileStore открывает журнал по пути filePath, воспроизводит его в память
+// и запускает сжатие раз в compactionInterval (0 — только по росту журнала)
+func NewFileStore(filePath string, compactionInterval time.Duration) (*FileStore, error) {
+	store := &FileStore{
+		index:     urlindex.New(),
+		clicks:    make(map[string]*analytics.Counter),
+		history:   make(map[string][]models.URLChange),
+		apiKeys:   make(map[string]*models.APIKey),
+		apiKeyIDs: make(map[string]string),
+		users:     make(map[string]*models.User),
+		sequences: make(map[string]uint64),
+		filePath:  filePath,
+		trigger:   make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	corrupted, err := store.replay()
+	if err != nil {
+		return nil, err
+	}
+	if err := store.loadClicks(); err != nil {
+		return nil, err
+	}
+	if err := store.loadHistory(); err != nil {
+		return nil, err
+	}
+	if err := store.loadAPIKeys(); err != nil {
+		return nil, err
+	}
+	if err := store.loadUsers(); err != nil {
+		return nil, err
+	}
+	if err := store.loadSequences(); err != nil {
+		return nil, err
+	}
+
+	// Битый хвост журнала (например, после аварийной остановки) нельзя
+	// оставлять: следующая запись склеилась бы с ним в одну строку
+	if corrupted {
+		logger.Log.Warn("File store journal is corrupted, compacting", "filePath", filePath)
+		err = store.compact()
+	} else {
+		store.file, err = os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	go store.runCompaction(compactionInterval)
+	return store, nil
 }
 
-// Set сохраняет URL в память и файл
-func (store *FileStore) Set(ctx context.Context, shortURL, originalURL, userID string) error {
-	store.mu.Lock()
-	defer store.mu.Unlock()
-	store.URLMapping[shortURL] = originalURL
-	logger.Log.Info("Saving URLMapping", "shortURL", shortURL, "originalURL", originalURL, "userID", userID)
-	logger.Log.Info("Store", "filePath:", store.FilePath)
-	store.SaveToFile(store.FilePath)
+// replay воспроизводит журнал в память. Возвращает true, если в журнале
+// встретились строки, которые не удалось разобрать
+func (store *FileStore) replay() (bool, error) {
+	file, err := os.Open(store.filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer file.Close()
+
+	corrupted := false
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var rec record
+			if jsonErr := json.Unmarshal(line, &rec); jsonErr != nil {
+				logger.Log.Error("Failed to decode file store record", "error", jsonErr)
+				corrupted = true
+			} else {
+				store.apply(rec)
+				store.records++
+			}
+		}
+		if err == io.EOF {
+			return corrupted, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
+
+// apply применяет запись журнала к состоянию в памяти
+func (store *FileStore) apply(rec record) {
+	switch rec.Op {
+	case opCreate, opUpdate, "":
+		url := rec.Storage
+		if url.ID == "" {
+			url.ID = uuid.New().String()
+		}
+		store.index.Put(&url)
+	case opDelete:
+		if url, exists := store.index.URLs[rec.ShortURL]; exists && url.UserID == rec.UserID {
+			url.DeletedFlag = true
+		}
+	case opPurge:
+		store.index.Remove(rec.ShortURL)
+		delete(store.clicks, rec.ShortURL)
+		delete(store.history, rec.ShortURL)
+	default:
+		logger.Log.Error("Unknown file store record", "op", rec.Op)
+	}
+}
+
+// write дописывает записи в журнал одним вызовом и сбрасывает их на диск,
+// после чего применяет их к состоянию в памяти
+func (store *FileStore) write(records ...record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, rec := range records {
+		if err := encoder.Encode(rec); err != nil {
+			return err
+		}
+	}
+
+	if _, err := store.file.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if err := store.file.Sync(); err != nil {
+		return err
+	}
+
+	for _, rec := range records {
+		store.apply(rec)
+	}
+	store.records += len(records)
+	if store.compacting {
+		store.tail = append(store.tail, records...)
+	}
+
+	if store.records >= compactMinRecords && store.records > compactRatio*len(store.index.URLs) {
+		store.requestCompaction()
+	}
+
 	return nil
 }
 
-// Get получает URL из памяти или из файла
-func (store *FileStore) Get(ctx context.Context, shortURL string) (*models.Storage, bool) {
+// requestCompaction просит фоновую горутину сжать журнал, не дожидаясь сжатия
+func (store *FileStore) requestCompaction() {
+	select {
+	case store.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// newRecord создаёт запись о новом URL
+func newRecord(url models.Storage) record {
+	url.ID = uuid.New().String()
+	url.DeletedFlag = false
+	return record{Op: opCreate, Storage: url}
+}
+
+// Set сохраняет URL в журнал.
+// Если оригинальный URL уже сокращён, возвращает *storeerrors.ErrConflict с сохранённым коротким URL,
+// если короткий URL уже занят другим оригинальным URL — storeerrors.ErrShortURLTaken
+func (store *FileStore) Set(ctx context.Context, url models.Storage) error {
 	store.mu.Lock()
 	defer store.mu.Unlock()
+	if err := store.index.CheckInsert(url.ShortURL, url.OriginalURL); err != nil {
+		return err
+	}
+	contextutils.Logger(ctx).Info("Saving URL", "shortURL", url.ShortURL, "originalURL", url.OriginalURL, "userID", url.UserID)
+	return store.write(newRecord(url))
+}
 
-	if err := store.LoadFromFile(store.FilePath); err != nil {
-		logger.Log.Error("Failed to load URL mapping from file", "error", err)
-		return nil, false
+// FindShortURL возвращает сгенерированный короткий URL, под которым уже сокращён оригинальный URL
+func (store *FileStore) FindShortURL(ctx context.Context, originalURL string) (string, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	shortURL, _ := store.index.FindOriginal(originalURL)
+	return shortURL, nil
+}
+
+// SetAlias сохраняет URL под пользовательским алиасом в журнал.
+// Если алиас уже занят, возвращает storeerrors.ErrAliasTaken
+func (store *FileStore) SetAlias(ctx context.Context, url models.Storage) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if _, exists := store.index.URLs[url.ShortURL]; exists {
+		return storeerrors.ErrAliasTaken
 	}
+	contextutils.Logger(ctx).Info("Saving alias", "alias", url.ShortURL, "originalURL", url.OriginalURL, "userID", url.UserID)
+	url.IsAlias = true
+	return store.write(newRecord(url))
+}
 
-	originalURL, exists := store.URLMapping[shortURL]
+// Get получает URL из памяти
+func (store *FileStore) Get(ctx context.Context, shortURL string) (*models.Storage, bool) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	url, exists := store.index.URLs[shortURL]
 	if !exists {
 		return nil, false
 	}
 
-	return &models.Storage{ShortURL: shortURL, OriginalURL: originalURL}, true
+	s := *url
+	return &s, true
+}
+
+// SetBatch сохраняет пакет URL в журнал одной записью на диск
+func (store *FileStore) SetBatch(ctx context.Context, shortURLS []models.BatchURLWrite, atomic bool) ([]models.BatchURLResult, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	results := make([]models.BatchURLResult, len(shortURLS))
+	records := make([]record, 0, len(shortURLS))
+	batch := store.index.NewBatch(len(shortURLS))
+	for i, url := range shortURLS {
+		results[i] = models.BatchURLResult{CorrelationID: url.CorrelationID, ShortURL: url.ShortURL, Status: models.BatchStatusCreated}
+
+		if existingShortURL, exists := batch.FindConflict(url.ShortURL, url.OriginalURL); exists {
+			if existingShortURL == "" {
+				results[i].Status = models.BatchStatusTaken
+			} else {
+				results[i].Status = models.BatchStatusExists
+				results[i].ShortURL = existingShortURL
+			}
+			continue
+		}
+
+		records = append(records, newRecord(models.Storage{
+			ShortURL:    url.ShortURL,
+			OriginalURL: url.OriginalURL,
+			UserID:      url.UserID,
+			ExpiresAt:   url.ExpiresAt,
+		}))
+	}
+
+	if atomic && len(records) < len(shortURLS) {
+		return results, storeerrors.ErrBatchRolledBack
+	}
+
+	if err := store.write(records...); err != nil {
+		return nil, err
+	}
+	contextutils.Logger(ctx).Info("Saved URL batch", "count", len(records))
+	return results, nil
+}
+
+// GetUserUrls получает URL пользователя из памяти
+func (store *FileStore) GetUserUrls(ctx context.Context, userID string) ([]models.URL, bool) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	var userUrls []models.URL
+	for _, url := range store.index.URLs {
+		if url.UserID == userID {
+			userUrls = append(userUrls, models.URL{ShortURL: url.ShortURL, OriginalURL: url.OriginalURL})
+		}
+	}
+
+	return userUrls, true
+}
+
+// EachGeneratedShortURL вызывает fn для каждого сгенерированного короткого URL
+func (store *FileStore) EachGeneratedShortURL(ctx context.Context, fn func(shortURL string)) error {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	for shortURL, url := range store.index.URLs {
+		if !url.IsAlias {
+			fn(shortURL)
+		}
+	}
+	return nil
+}
+
+// CountUserUrls возвращает число действующих URL пользователя
+func (store *FileStore) CountUserUrls(ctx context.Context, userID string) (int, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	now := time.Now()
+	var count int
+	for _, url := range store.index.URLs {
+		if url.UserID == userID && !url.DeletedFlag && !url.Expired(now) {
+			count++
+		}
+	}
+	return count, nil
 }
 
-// SetBatch сохраняет URL в память и файл
-func (store *FileStore) SetBatch(ctx context.Context, shortURLS []models.BatchURLWrite) {
+// DeleteUserUrls записывает в журнал удаление URL, принадлежащих пользователю
+func (store *FileStore) DeleteUserUrls(ctx context.Context, userURLs []models.UserURL) error {
 	store.mu.Lock()
 	defer store.mu.Unlock()
-	for _, urlMapping := range shortURLS {
-		store.URLMapping[urlMapping.ShortURL] = urlMapping.OriginalURL
-		logger.Log.Info("Saving URLMapping", "shortURL", urlMapping.ShortURL, "originalURL", urlMapping.OriginalURL)
+
+	var records []record
+	for _, userURL := range userURLs {
+		if url, exists := store.index.URLs[userURL.ShortURL]; exists && url.UserID == userURL.UserID && !url.DeletedFlag {
+			records = append(records, record{
+				Op:      opDelete,
+				Storage: models.Storage{ShortURL: userURL.ShortURL, UserID: userURL.UserID},
+			})
+		}
+	}
+
+	return store.write(records...)
+}
+
+// DeleteExpired удаляет URL, срок действия которых истёк раньше before
+func (store *FileStore) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	var records []record
+	for shortURL, url := range store.index.URLs {
+		if url.ExpiresAt != nil && url.ExpiresAt.Before(before) {
+			records = append(records, record{Op: opPurge, Storage: models.Storage{ShortURL: shortURL}})
+		}
+	}
+
+	if err := store.write(records...); err != nil {
+		return 0, err
 	}
-	store.SaveToFile(store.FilePath)
+	if len(records) > 0 {
+		// Иначе после перезапуска события и история удалённых URL достались бы URL,
+		// занявшим те же коды. При ошибке файлы будут переписаны при следующей загрузке
+		if err := store.rewriteClicks(); err != nil {
+			contextutils.Logger(ctx).Error("Failed to rewrite clicks file", "error", err)
+		}
+		if err := store.rewriteHistory(); err != nil {
+			contextutils.Logger(ctx).Error("Failed to rewrite history file", "error", err)
+		}
+	}
+	return int64(len(records)), nil
 }
 
-// SaveToFile сохраняет URL-маппинг в JSON файл
-func (store *FileStore) SaveToFile(filePath string) error {
-	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE, 0644)
+// Compact сжимает журнал в снимок живых URL
+func (store *FileStore) Compact() error {
+	return store.compact()
+}
+
+// compact сжимает журнал в снимок живых URL, а файл переходов — в накопленную
+// статистику. Снимок снимается под store.mu, но пишется на диск без блокировки,
+// чтобы чтения и перенаправления не ждали записи. Сделанные за это время записи
+// дописываются в новые файлы под блокировкой перед атомарной подменой.
+// Одновременно выполняется не больше одного сжатия
+func (store *FileStore) compact() error {
+	store.compactMu.Lock()
+	defer store.compactMu.Unlock()
+
+	store.mu.Lock()
+	urls := make([]models.Storage, 0, len(store.index.URLs))
+	for _, url := range store.index.URLs {
+		urls = append(urls, *url)
+	}
+	var clicks []clickSnapshot
+	if store.clickRecords > len(store.clicks) {
+		clicks = make([]clickSnapshot, 0, len(store.clicks))
+		for shortURL, counter := range store.clicks {
+			clicks = append(clicks, clickSnapshot{ShortURL: shortURL, Days: counter.Snapshot()})
+		}
+	}
+	clickRewrites := store.clickRewrites
+	store.compacting = true
+	store.mu.Unlock()
+
+	defer func() {
+		store.mu.Lock()
+		store.compacting = false
+		store.tail, store.clickTail = nil, nil
+		store.mu.Unlock()
+	}()
+
+	journal, err := createTemp(store.filePath)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
+	defer journal.abort()
+	for _, url := range urls {
+		if err := journal.encoder.Encode(record{Op: opCreate, Storage: url}); err != nil {
+			return err
+		}
+	}
+	if err := journal.sync(); err != nil {
+		return err
+	}
+
+	var clicksFile *tempFile
+	if clicks != nil {
+		clicksFile, err = store.writeClickSnapshots(clicks)
+		if err != nil {
+			logger.Log.Error("Failed to compact clicks file", "error", err)
+		}
+		defer clicksFile.abort()
+	}
 
-	encoder := json.NewEncoder(file)
-	for shortURL, originalURL := range store.URLMapping {
-		logger.Log.Info("Encoding URLMapping", "shortURL", shortURL, "originalURL", originalURL)
-		if err := encoder.Encode(URLMapping{ShortURL: shortURL, OriginalURL: originalURL}); err != nil {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	for _, rec := range store.tail {
+		if err := journal.encoder.Encode(rec); err != nil {
 			return err
 		}
 	}
+	if err := journal.commit(); err != nil {
+		return err
+	}
+
+	// Старый дескриптор указывает на заменённый файл
+	if store.file != nil {
+		store.file.Close()
+	}
+	store.file, err = os.OpenFile(store.filePath, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
 
+	logger.Log.Info("Compacted file store", "records", store.records, "urls", len(store.index.URLs))
+	store.records = len(urls) + len(store.tail)
+
+	// Если файл переходов успели переписать в обход сжатия, он уже сжат
+	if clicksFile != nil && clickRewrites == store.clickRewrites {
+		if err := store.commitClickSnapshots(clicksFile, len(clicks)); err != nil {
+			logger.Log.Error("Failed to compact clicks file", "error", err)
+		}
+	}
 	return nil
 }
 
-// LoadFromFile загружает URL-маппинг из JSON файла
-func (store *FileStore) LoadFromFile(filePath string) error {
-	file, err := os.Open(filePath)
+// writeClickSnapshots записывает статистику переходов во временный файл
+func (store *FileStore) writeClickSnapshots(clicks []clickSnapshot) (*tempFile, error) {
+	file, err := createTemp(store.clicksFilePath())
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil
+		return nil, err
+	}
+	for _, snapshot := range clicks {
+		if err := file.encoder.Encode(snapshot); err != nil {
+			file.abort()
+			return nil, err
 		}
+	}
+	if err := file.sync(); err != nil {
+		file.abort()
+		return nil, err
+	}
+	return file, nil
+}
+
+// commitClickSnapshots дописывает накопленные за сжатие события переходов
+// и подменяет файл переходов. Вызывается под store.mu
+func (store *FileStore) commitClickSnapshots(file *tempFile, snapshots int) error {
+	for _, click := range store.clickTail {
+		if err := file.encoder.Encode(click); err != nil {
+			return err
+		}
+	}
+	if err := file.commit(); err != nil {
 		return err
 	}
-	defer file.Close()
+	store.clickRecords = snapshots + len(store.clickTail)
+	return nil
+}
+
+// rewriteFile заменяет файл path записями, которые пишет encode, через временный файл и rename
+func rewriteFile(path string, encode func(encoder *json.Encoder) error) error {
+	file, err := createTemp(path)
+	if err != nil {
+		return err
+	}
+	defer file.abort()
+
+	if err := encode(file.encoder); err != nil {
+		return err
+	}
+	return file.commit()
+}
+
+// tempFile временный файл рядом с path, который после записи атомарно подменяет path
+type tempFile struct {
+	path    string
+	file    *os.File
+	writer  *bufio.Writer
+	encoder *json.Encoder
+}
+
+// createTemp создаёт временный файл с уникальным именем, чтобы одновременные
+// перезаписи одного файла не писали в один и тот же временный файл
+func createTemp(path string) (*tempFile, error) {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	writer := bufio.NewWriter(file)
+	return &tempFile{path: path, file: file, writer: writer, encoder: json.NewEncoder(writer)}, nil
+}
+
+// sync сбрасывает записанное на диск
+func (t *tempFile) sync() error {
+	if err := t.writer.Flush(); err != nil {
+		return err
+	}
+	return t.file.Sync()
+}
+
+// commit сбрасывает записанное на диск и подменяет временным файлом path
+func (t *tempFile) commit() error {
+	err := t.sync()
+	if closeErr := t.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(t.file.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(t.file.Name(), t.path)
+	}
+	if err != nil {
+		return err
+	}
+	t.file = nil
+
+	syncDir(filepath.Dir(t.path))
+	return nil
+}
+
+// abort удаляет временный файл, если он не подменил path
+func (t *tempFile) abort() {
+	if t == nil || t.file == nil {
+		return
+	}
+	t.file.Close()
+	os.Remove(t.file.Name())
+	t.file = nil
+}
+
+// syncDir сбрасывает на диск запись каталога, чтобы rename пережил сбой питания
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+	d.Sync()
+}
+
+// runCompaction сжимает журнал раз в interval и по запросу requestCompaction до вызова Close
+func (store *FileStore) runCompaction(interval time.Duration) {
+	defer close(store.done)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
 
-	decoder := json.NewDecoder(file)
 	for {
-		var urlMapping URLMapping
-		if err := decoder.Decode(&urlMapping); err != nil {
-			break
+		select {
+		case <-store.stop:
+			return
+		case <-tick:
+		case <-store.trigger:
+		}
+
+		store.mu.RLock()
+		needed := store.records > len(store.index.URLs) || store.clickRecords > len(store.clicks)
+		store.mu.RUnlock()
+		if needed {
+			if err := store.compact(); err != nil {
+				logger.Log.Error("Failed to compact file store", "error", err)
+			}
 		}
-		store.URLMapping[urlMapping.ShortURL] = urlMapping.OriginalURL
 	}
+}
 
-	return nil
+// Close останавливает сжатие, сбрасывает журнал на диск и закрывает его. Повторные вызовы безопасны
+func (store *FileStore) Close() error {
+	store.closeOnce.Do(func() {
+		close(store.stop)
+		<-store.done
+
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		store.closeErr = errors.Join(store.file.Sync(), store.file.Close())
+	})
+	return store.closeErr
 }
 
-// GetUserUrls получает URL пользователя из памяти или файла
-func (store *FileStore) GetUserUrls(ctx context.Context, userID string) ([]models.URL, bool) {
+// clicksFilePath путь к файлу с событиями переходов рядом с основным файлом хранилища.
+// События дописываются в конец файла, а при сжатии он переписывается накопленной
+// статистикой: по строке clickSnapshot на каждый короткий URL
+func (store *FileStore) clicksFilePath() string {
+	return store.filePath + ".clicks"
+}
+
+// clickSnapshot накопленная статистика переходов по короткому URL в файле переходов
+type clickSnapshot struct {
+	ShortURL string                  `json:"short_url"`
+	Days     []analytics.DaySnapshot `json:"days"`
+}
+
+// clickLine строка файла переходов: событие перехода или clickSnapshot
+type clickLine struct {
+	models.Click
+	Days []analytics.DaySnapshot `json:"days"`
+}
+
+// SaveClicks учитывает пачку событий перехода в памяти и дописывает их в файл
+func (store *FileStore) SaveClicks(ctx context.Context, clicks []models.Click) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, click := range clicks {
+		if err := encoder.Encode(click); err != nil {
+			return err
+		}
+	}
+
 	store.mu.Lock()
 	defer store.mu.Unlock()
-	store.LoadFromFile(store.FilePath)
 
-	var userUrls []models.URL
-	for shortURL, originalURL := range store.URLMapping {
-		userUrls = append(userUrls, models.URL{ShortURL: shortURL, OriginalURL: originalURL})
+	file, err := os.OpenFile(store.clicksFilePath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
 	}
+	defer file.Close()
 
-	return userUrls, true
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+
+	for _, click := range clicks {
+		store.counter(click.ShortURL).Add(click)
+	}
+	store.clickRecords += len(clicks)
+	if store.compacting {
+		store.clickTail = append(store.clickTail, clicks...)
+	}
+
+	if store.clickRecords >= compactMinRecords && store.clickRecords > compactRatio*len(store.clicks) {
+		store.requestCompaction()
+	}
+
+	return nil
 }
 
-// DeleteUserUrls удаляет URL пользователя из памяти и файла
-func (store *FileStore) DeleteUserUrls(ctx context.Context, deleteUserURLs <-chan models.UserURL) {
-	store.mu.Lock()
-	defer store.mu.Unlock()
-	store.LoadFromFile(store.FilePath)
+// counter возвращает статистику переходов по короткому URL, создавая её при необходимости.
+// Вызывается под store.mu
+func (store *FileStore) counter(shortURL string) *analytics.Counter {
+	counter, ok := store.clicks[shortURL]
+	if !ok {
+		counter = analytics.NewCounter()
+		store.clicks[shortURL] = counter
+	}
+	return counter
+}
+
+// rewriteClicks переписывает файл переходов накопленной статистикой из памяти,
+// отбрасывая статистику удалённых URL. Вызывается под store.mu
+func (store *FileStore) rewriteClicks() error {
+	err := rewriteFile(store.clicksFilePath(), func(encoder *json.Encoder) error {
+		for shortURL, counter := range store.clicks {
+			if err := encoder.Encode(clickSnapshot{ShortURL: shortURL, Days: counter.Snapshot()}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	store.clickRecords = len(store.clicks)
+	store.clickRewrites++
+	return nil
+}
+
+// loadClicks загружает статистику переходов по известным коротким URL из файла.
+// Если в файле остались переходы удалённых URL, файл переписывается без них
+func (store *FileStore) loadClicks() error {
+	file, err := os.Open(store.clicksFilePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	stale := false
+	decoder := json.NewDecoder(file)
+	for {
+		var line clickLine
+		if err := decoder.Decode(&line); err != nil {
+			break
+		}
+		store.clickRecords++
+		if _, exists := store.index.URLs[line.ShortURL]; !exists {
+			stale = true
+			continue
+		}
 
-	for userURL := range deleteUserURLs {
-		delete(store.URLMapping, userURL.ShortURL)
+		counter := store.counter(line.ShortURL)
+		if line.Days == nil {
+			counter.Add(line.Click)
+			continue
+		}
+		for _, day := range line.Days {
+			counter.AddSnapshot(day)
+		}
+	}
+
+	if stale {
+		return store.rewriteClicks()
 	}
+	return nil
+}
 
-	store.SaveToFile(store.FilePath)
+// GetClickStats получает статистику переходов по короткому URL
+func (store *FileStore) GetClickStats(ctx context.Context, shortURL string) (*models.ClickStats, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	counter, ok := store.clicks[shortURL]
+	if !ok {
+		counter = analytics.NewCounter()
+	}
+	return counter.Stats(shortURL), nil
 }
 
 // Ping проверяет доступность хранилища URL
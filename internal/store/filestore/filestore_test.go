@@ -0,0 +1,299 @@
+package filestore
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/learies/go-url-shortener/internal/logger"
+	"github.com/learies/go-url-shortener/internal/models"
+	"github.com/learies/go-url-shortener/internal/store/storeerrors"
+)
+
+func newTestStore(t *testing.T, filePath string) *FileStore {
+	store, err := NewFileStore(filePath, 0)
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func countLines(t *testing.T, filePath string) int {
+	file, err := os.Open(filePath)
+	require.NoError(t, err)
+	defer file.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines++
+	}
+	return lines
+}
+
+func TestFileStore(t *testing.T) {
+	require.NoError(t, logger.Initialize("error"))
+	ctx := context.Background()
+	filePath := filepath.Join(t.TempDir(), "urls.json")
+
+	t.Run("loads legacy records", func(t *testing.T) {
+		legacy := `{"short_url":"legacy01","original_url":"http://example.com/legacy"}` + "\n"
+		require.NoError(t, os.WriteFile(filePath, []byte(legacy), 0644))
+
+		store := newTestStore(t, filePath)
+		s, exists := store.Get(ctx, "legacy01")
+		require.True(t, exists)
+		assert.Equal(t, "http://example.com/legacy", s.OriginalURL)
+		assert.NotEmpty(t, s.ID)
+		assert.Empty(t, s.UserID)
+		assert.False(t, s.DeletedFlag)
+	})
+
+	t.Run("replays owners and deletions from the journal", func(t *testing.T) {
+		store := newTestStore(t, filePath)
+		require.NoError(t, store.Set(ctx, models.Storage{ShortURL: "user1url", OriginalURL: "http://example.com/1", UserID: "user1"}))
+		require.NoError(t, store.Set(ctx, models.Storage{ShortURL: "user2url", OriginalURL: "http://example.com/2", UserID: "user2"}))
+
+		require.NoError(t, store.DeleteUserUrls(ctx, []models.UserURL{
+			{UserID: "user1", ShortURL: "user1url"},
+			{UserID: "user1", ShortURL: "user2url"},
+		}))
+		require.NoError(t, store.Close())
+
+		// Легаси-строка, две записи о создании и одна об удалении
+		assert.Equal(t, 4, countLines(t, filePath))
+
+		reloaded := newTestStore(t, filePath)
+
+		s, exists := reloaded.Get(ctx, "user1url")
+		require.True(t, exists)
+		assert.True(t, s.DeletedFlag)
+		assert.Equal(t, "user1", s.UserID)
+
+		s, exists = reloaded.Get(ctx, "user2url")
+		require.True(t, exists)
+		assert.False(t, s.DeletedFlag)
+
+		urls, _ := reloaded.GetUserUrls(ctx, "user2")
+		assert.Equal(t, []models.URL{{ShortURL: "user2url", OriginalURL: "http://example.com/2"}}, urls)
+	})
+
+	t.Run("compacts the journal into a snapshot", func(t *testing.T) {
+		store := newTestStore(t, filePath)
+		expiresAt := time.Now().Add(-time.Hour)
+		require.NoError(t, store.Set(ctx, models.Storage{ShortURL: "expired", OriginalURL: "http://example.com/old", ExpiresAt: &expiresAt}))
+
+		deleted, err := store.DeleteExpired(ctx, time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), deleted)
+
+		require.NoError(t, store.Compact())
+		assert.Equal(t, 3, countLines(t, filePath))
+
+		// Журнал продолжает дописываться после подмены файла
+		require.NoError(t, store.Set(ctx, models.Storage{ShortURL: "after", OriginalURL: "http://example.com/after", RedirectType: 308}))
+		require.NoError(t, store.Close())
+
+		reloaded := newTestStore(t, filePath)
+		_, exists := reloaded.Get(ctx, "expired")
+		assert.False(t, exists)
+		s, exists := reloaded.Get(ctx, "after")
+		require.True(t, exists)
+		assert.Equal(t, 308, s.RedirectType)
+		s, exists = reloaded.Get(ctx, "user1url")
+		require.True(t, exists)
+		assert.True(t, s.DeletedFlag)
+	})
+
+	t.Run("recovers from a truncated record", func(t *testing.T) {
+		file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_APPEND, 0644)
+		require.NoError(t, err)
+		_, err = file.WriteString(`{"op":"create","short_url":"trunc`)
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+
+		store := newTestStore(t, filePath)
+		require.NoError(t, store.Set(ctx, models.Storage{ShortURL: "next", OriginalURL: "http://example.com/next"}))
+		require.NoError(t, store.Close())
+
+		reloaded := newTestStore(t, filePath)
+		_, exists := reloaded.Get(ctx, "next")
+		assert.True(t, exists)
+		_, exists = reloaded.Get(ctx, "after")
+		assert.True(t, exists)
+	})
+
+	t.Run("persists API key revocation", func(t *testing.T) {
+		store := newTestStore(t, filePath)
+		key := models.APIKey{ID: "key1", UserID: "user1", Name: "ci", KeyHash: "hash1", CreatedAt: time.Now()}
+		require.NoError(t, store.CreateAPIKey(ctx, key))
+		require.NoError(t, store.CreateAPIKey(ctx, models.APIKey{ID: "key2", UserID: "user1", Name: "deploy", KeyHash: "hash2", CreatedAt: time.Now()}))
+		assert.ErrorIs(t, store.RevokeAPIKey(ctx, "user2", "key1"), storeerrors.ErrAPIKeyNotFound)
+		require.NoError(t, store.RevokeAPIKey(ctx, "user1", "key1"))
+		require.NoError(t, store.Close())
+
+		reloaded := newTestStore(t, filePath)
+		_, err := reloaded.GetAPIKey(ctx, "hash1")
+		assert.ErrorIs(t, err, storeerrors.ErrAPIKeyNotFound)
+
+		found, err := reloaded.GetAPIKey(ctx, "hash2")
+		require.NoError(t, err)
+		assert.Equal(t, "user1", found.UserID)
+
+		keys, err := reloaded.GetUserAPIKeys(ctx, "user1")
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+		assert.Equal(t, "deploy", keys[0].Name)
+	})
+
+	t.Run("persists original URL changes and history", func(t *testing.T) {
+		store := newTestStore(t, filePath)
+		require.NoError(t, store.Set(ctx, models.Storage{ShortURL: "edit", OriginalURL: "http://example.com/v1", UserID: "user1"}))
+
+		_, err := store.UpdateOriginalURL(ctx, models.URLChange{ID: "c0", ShortURL: "edit", NewURL: "http://example.com/x", ChangedBy: "user2"})
+		assert.ErrorIs(t, err, storeerrors.ErrURLNotFound)
+
+		change, err := store.UpdateOriginalURL(ctx, models.URLChange{ID: "c1", ShortURL: "edit", NewURL: "http://example.com/v2", ChangedBy: "user1", ChangedAt: time.Now()})
+		require.NoError(t, err)
+		assert.Equal(t, "http://example.com/v1", change.OldURL)
+		require.NoError(t, store.Close())
+
+		reloaded := newTestStore(t, filePath)
+		url, ok := reloaded.Get(ctx, "edit")
+		require.True(t, ok)
+		assert.Equal(t, "http://example.com/v2", url.OriginalURL)
+
+		history, err := reloaded.GetURLHistory(ctx, "edit")
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		assert.Equal(t, "c1", history[0].ID)
+		assert.Equal(t, "http://example.com/v1", history[0].OldURL)
+		assert.Equal(t, "http://example.com/v2", history[0].NewURL)
+		assert.Equal(t, "user1", history[0].ChangedBy)
+	})
+
+	t.Run("rejects duplicate original URLs after reload", func(t *testing.T) {
+		store := newTestStore(t, filePath)
+		require.NoError(t, store.Set(ctx, models.Storage{ShortURL: "dup1", OriginalURL: "http://example.com/dup", UserID: "user1"}))
+		require.NoError(t, store.SetAlias(ctx, models.Storage{ShortURL: "dup-alias", OriginalURL: "http://example.com/dup", UserID: "user1"}))
+		require.NoError(t, store.Close())
+
+		reloaded := newTestStore(t, filePath)
+		err := reloaded.Set(ctx, models.Storage{ShortURL: "dup2", OriginalURL: "http://example.com/dup", UserID: "user2"})
+		var conflict *storeerrors.ErrConflict
+		require.ErrorAs(t, err, &conflict)
+		assert.Equal(t, "dup1", conflict.ShortURL)
+
+		results, err := reloaded.SetBatch(ctx, []models.BatchURLWrite{
+			{CorrelationID: "1", ShortURL: "dup3", OriginalURL: "http://example.com/dup"},
+		}, false)
+		require.NoError(t, err)
+		assert.Equal(t, models.BatchStatusExists, results[0].Status)
+		assert.Equal(t, "dup1", results[0].ShortURL)
+	})
+
+	t.Run("drops clicks of purged URLs", func(t *testing.T) {
+		store := newTestStore(t, filePath)
+		expiresAt := time.Now().Add(-time.Hour)
+		require.NoError(t, store.Set(ctx, models.Storage{ShortURL: "reused", OriginalURL: "http://example.com/first", ExpiresAt: &expiresAt}))
+		require.NoError(t, store.SaveClicks(ctx, []models.Click{{ShortURL: "reused", Timestamp: time.Now(), IP: "10.0.0.1"}}))
+
+		_, err := store.DeleteExpired(ctx, time.Now())
+		require.NoError(t, err)
+		require.NoError(t, store.Set(ctx, models.Storage{ShortURL: "reused", OriginalURL: "http://example.com/second"}))
+		require.NoError(t, store.Close())
+
+		reloaded := newTestStore(t, filePath)
+		stats, err := reloaded.GetClickStats(ctx, "reused")
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), stats.TotalClicks)
+	})
+
+	t.Run("drops history of purged URLs", func(t *testing.T) {
+		store := newTestStore(t, filePath)
+		expiresAt := time.Now().Add(-time.Hour)
+		require.NoError(t, store.Set(ctx, models.Storage{ShortURL: "rehomed", OriginalURL: "http://example.com/old-v1", UserID: "user1", ExpiresAt: &expiresAt}))
+		_, err := store.UpdateOriginalURL(ctx, models.URLChange{ID: "h1", ShortURL: "rehomed", NewURL: "http://example.com/old-v2", ChangedBy: "user1"})
+		require.NoError(t, err)
+
+		_, err = store.DeleteExpired(ctx, time.Now())
+		require.NoError(t, err)
+		require.NoError(t, store.Set(ctx, models.Storage{ShortURL: "rehomed", OriginalURL: "http://example.com/new", UserID: "user2"}))
+		require.NoError(t, store.Close())
+
+		reloaded := newTestStore(t, filePath)
+		history, err := reloaded.GetURLHistory(ctx, "rehomed")
+		require.NoError(t, err)
+		assert.Empty(t, history)
+	})
+
+	t.Run("keeps writes made during compaction", func(t *testing.T) {
+		store := newTestStore(t, filePath)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 20; i++ {
+				assert.NoError(t, store.Compact())
+			}
+		}()
+		for i := 0; i < 200; i++ {
+			shortURL := fmt.Sprintf("during%d", i)
+			require.NoError(t, store.Set(ctx, models.Storage{ShortURL: shortURL, OriginalURL: "http://example.com/" + shortURL}))
+		}
+		<-done
+		require.NoError(t, store.Close())
+
+		reloaded := newTestStore(t, filePath)
+		for i := 0; i < 200; i++ {
+			_, ok := reloaded.Get(ctx, fmt.Sprintf("during%d", i))
+			assert.True(t, ok)
+		}
+	})
+
+	t.Run("persists sequences", func(t *testing.T) {
+		store := newTestStore(t, filePath)
+		value, err := store.ReserveSequence(ctx, "short_url_counter", 1000)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(0), value)
+		require.NoError(t, store.Close())
+
+		reloaded := newTestStore(t, filePath)
+		value, err = reloaded.ReserveSequence(ctx, "short_url_counter", 1000)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1000), value)
+	})
+
+	t.Run("compacts clicks into statistics", func(t *testing.T) {
+		store := newTestStore(t, filePath)
+		require.NoError(t, store.Set(ctx, models.Storage{ShortURL: "popular", OriginalURL: "http://example.com/popular"}))
+
+		day := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+		clicks := make([]models.Click, compactMinRecords)
+		for i := range clicks {
+			clicks[i] = models.Click{ShortURL: "popular", Timestamp: day, IP: "192.0.2.0"}
+		}
+		require.NoError(t, store.SaveClicks(ctx, clicks))
+		require.NoError(t, store.Compact())
+		assert.Equal(t, 1, countLines(t, filePath+".clicks"))
+
+		require.NoError(t, store.SaveClicks(ctx, []models.Click{{ShortURL: "popular", Timestamp: day.Add(24 * time.Hour), IP: "198.51.100.0"}}))
+		require.NoError(t, store.Close())
+
+		reloaded := newTestStore(t, filePath)
+		stats, err := reloaded.GetClickStats(ctx, "popular")
+		require.NoError(t, err)
+		assert.Equal(t, int64(compactMinRecords+1), stats.TotalClicks)
+		assert.Equal(t, int64(2), stats.UniqueVisitors)
+		assert.Equal(t, []models.DailyClicks{
+			{Date: "2024-10-01", Clicks: compactMinRecords, UniqueVisitors: 1},
+			{Date: "2024-10-02", Clicks: 1, UniqueVisitors: 1},
+		}, stats.Daily)
+	})
+}
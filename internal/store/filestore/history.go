@@ -0,0 +1,133 @@
+package filestore
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/learies/go-url-shortener/internal/contextutils"
+	"github.com/learies/go-url-shortener/internal/models"
+	"github.com/learies/go-url-shortener/internal/store/storeerrors"
+)
+
+// historyFilePath путь к файлу с историей изменений рядом с основным файлом хранилища
+func (store *FileStore) historyFilePath() string {
+	return store.filePath + ".history"
+}
+
+// UpdateOriginalURL дописывает изменение в историю, а новое состояние URL — в журнал.
+// Если новый оригинальный URL уже сокращён, возвращает *storeerrors.ErrConflict.
+// Журнал пишется первым: если запись в историю не удалась, изменение
+// откатывается, чтобы в истории не оказалось изменений, которых нет в журнале
+func (store *FileStore) UpdateOriginalURL(ctx context.Context, change models.URLChange) (*models.URLChange, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	url, exists := store.index.URLs[change.ShortURL]
+	if !exists || url.UserID != change.ChangedBy || url.DeletedFlag {
+		return nil, storeerrors.ErrURLNotFound
+	}
+	if !url.IsAlias {
+		if shortURL, exists := store.index.FindOriginal(change.NewURL); exists && shortURL != change.ShortURL {
+			return nil, &storeerrors.ErrConflict{ShortURL: shortURL}
+		}
+	}
+	change.OldURL = url.OriginalURL
+
+	previous := *url
+	updated := *url
+	updated.OriginalURL = change.NewURL
+	if err := store.write(record{Op: opUpdate, Storage: updated}); err != nil {
+		return nil, err
+	}
+
+	if err := store.writeChange(change); err != nil {
+		if undoErr := store.write(record{Op: opUpdate, Storage: previous}); undoErr != nil {
+			contextutils.Logger(ctx).Error("Failed to undo original URL update", "shortURL", change.ShortURL, "error", undoErr)
+		}
+		return nil, err
+	}
+	contextutils.Logger(ctx).Info("Updated original URL", "shortURL", change.ShortURL, "originalURL", change.NewURL, "userID", change.ChangedBy)
+	return &change, nil
+}
+
+// GetURLHistory возвращает историю изменений оригинального URL в порядке их внесения
+func (store *FileStore) GetURLHistory(ctx context.Context, shortURL string) ([]models.URLChange, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	return append([]models.URLChange{}, store.history[shortURL]...), nil
+}
+
+// writeChange дописывает изменение в файл истории и добавляет его в память.
+// Вызывается под store.mu
+func (store *FileStore) writeChange(change models.URLChange) error {
+	data, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(store.historyFilePath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+
+	store.history[change.ShortURL] = append(store.history[change.ShortURL], change)
+	return nil
+}
+
+// rewriteHistory переписывает файл истории историей из памяти, отбрасывая
+// изменения удалённых URL. Вызывается под store.mu
+func (store *FileStore) rewriteHistory() error {
+	return rewriteFile(store.historyFilePath(), func(encoder *json.Encoder) error {
+		for _, changes := range store.history {
+			for _, change := range changes {
+				if err := encoder.Encode(change); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// loadHistory загружает историю изменений известных коротких URL из файла.
+// Если в файле осталась история удалённых URL, файл переписывается без неё,
+// чтобы она не досталась URL, который займёт тот же код
+func (store *FileStore) loadHistory() error {
+	file, err := os.Open(store.historyFilePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	stale := false
+	decoder := json.NewDecoder(file)
+	for {
+		var change models.URLChange
+		if err := decoder.Decode(&change); err != nil {
+			break
+		}
+		if _, exists := store.index.URLs[change.ShortURL]; exists {
+			store.history[change.ShortURL] = append(store.history[change.ShortURL], change)
+		} else {
+			stale = true
+		}
+	}
+
+	if stale {
+		return store.rewriteHistory()
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package filestore
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+)
+
+// sequence значение постоянного счётчика в файле счётчиков
+type sequence struct {
+	Name  string `json:"name"`
+	Value uint64 `json:"value"`
+}
+
+// sequencesFilePath путь к файлу со счётчиками рядом с основным файлом хранилища
+func (store *FileStore) sequencesFilePath() string {
+	return store.filePath + ".sequences"
+}
+
+// ReserveSequence увеличивает счётчик name на size и возвращает его прежнее значение.
+// Файл счётчиков небольшой, поэтому каждый раз переписывается целиком
+func (store *FileStore) ReserveSequence(ctx context.Context, name string, size uint64) (uint64, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	value := store.sequences[name]
+	if size == 0 {
+		return value, nil
+	}
+
+	store.sequences[name] = value + size
+	err := rewriteFile(store.sequencesFilePath(), func(encoder *json.Encoder) error {
+		for name, value := range store.sequences {
+			if err := encoder.Encode(sequence{Name: name, Value: value}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		store.sequences[name] = value
+		return 0, err
+	}
+	return value, nil
+}
+
+// loadSequences загружает счётчики из файла
+func (store *FileStore) loadSequences() error {
+	file, err := os.Open(store.sequencesFilePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	for {
+		var seq sequence
+		if err := decoder.Decode(&seq); err != nil {
+			break
+		}
+		store.sequences[seq.Name] = seq.Value
+	}
+
+	return nil
+}
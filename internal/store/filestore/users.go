@@ -0,0 +1,118 @@
+package filestore
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/learies/go-url-shortener/internal/models"
+	"github.com/learies/go-url-shortener/internal/store/storeerrors"
+)
+
+// usersFilePath путь к файлу с зарегистрированными пользователями рядом с основным файлом хранилища
+func (store *FileStore) usersFilePath() string {
+	return store.filePath + ".users"
+}
+
+// CreateUser регистрирует пользователя и дописывает его в файл
+func (store *FileStore) CreateUser(ctx context.Context, user models.User) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	for _, existing := range store.users {
+		if existing.Login == user.Login {
+			return storeerrors.ErrLoginTaken
+		}
+	}
+
+	data, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(store.usersFilePath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+
+	store.users[user.ID] = &user
+	return nil
+}
+
+// GetUserByLogin ищет пользователя по логину
+func (store *FileStore) GetUserByLogin(ctx context.Context, login string) (*models.User, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	for _, user := range store.users {
+		if user.Login == login {
+			found := *user
+			return &found, nil
+		}
+	}
+	return nil, storeerrors.ErrUserNotFound
+}
+
+// GetUser ищет пользователя по ID
+func (store *FileStore) GetUser(ctx context.Context, userID string) (*models.User, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	user, exists := store.users[userID]
+	if !exists {
+		return nil, storeerrors.ErrUserNotFound
+	}
+	found := *user
+	return &found, nil
+}
+
+// ClaimUserUrls записывает в журнал смену владельца URL пользователя fromUserID
+func (store *FileStore) ClaimUserUrls(ctx context.Context, fromUserID, toUserID string) (int64, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	var records []record
+	for _, url := range store.index.URLs {
+		if url.UserID == fromUserID {
+			claimed := *url
+			claimed.UserID = toUserID
+			records = append(records, record{Op: opUpdate, Storage: claimed})
+		}
+	}
+
+	if err := store.write(records...); err != nil {
+		return 0, err
+	}
+	return int64(len(records)), nil
+}
+
+// loadUsers загружает зарегистрированных пользователей из файла
+func (store *FileStore) loadUsers() error {
+	file, err := os.Open(store.usersFilePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	for {
+		var user models.User
+		if err := decoder.Decode(&user); err != nil {
+			break
+		}
+		store.users[user.ID] = &user
+	}
+
+	return nil
+}
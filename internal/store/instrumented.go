@@ -0,0 +1,274 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/learies/go-url-shortener/internal/models"
+	"github.com/learies/go-url-shortener/internal/store/dbstore"
+	"github.com/learies/go-url-shortener/internal/store/filestore"
+	"github.com/learies/go-url-shortener/internal/store/memstore"
+	"github.com/learies/go-url-shortener/internal/store/storeerrors"
+	"github.com/learies/go-url-shortener/internal/tracing"
+)
+
+// OpObserver получает имя хранилища, операцию, её длительность и сбой, если он был
+type OpObserver func(backend, operation string, duration time.Duration, err error)
+
+// instrumentedStore передаёт вызовы хранилищу, оборачивая каждый спаном трассировки
+// и сообщая о его длительности и результате observe
+type instrumentedStore struct {
+	store   Store
+	backend string
+	observe OpObserver
+}
+
+// operation выполняющаяся операция хранилища
+type operation struct {
+	name  string
+	start time.Time
+	span  trace.Span
+}
+
+// Instrument оборачивает хранилище трассировкой и сообщает observe о каждой операции
+func Instrument(s Store, observe OpObserver) Store {
+	return &instrumentedStore{store: s, backend: backendName(s), observe: observe}
+}
+
+func backendName(s Store) string {
+	switch s.(type) {
+	case *dbstore.DBStore:
+		return "postgres"
+	case *filestore.FileStore:
+		return "file"
+	case *memstore.MemStore:
+		return "memory"
+	default:
+		return "unknown"
+	}
+}
+
+// begin начинает спан операции name
+func (s *instrumentedStore) begin(ctx context.Context, name string) (context.Context, *operation) {
+	ctx, span := tracing.Tracer().Start(ctx, "store."+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("store.backend", s.backend)),
+	)
+	return ctx, &operation{name: name, start: time.Now(), span: span}
+}
+
+// done завершает операцию. Ожидаемые ошибки хранилища описывают
+// результат операции, а не сбой, и не учитываются как ошибки
+func (s *instrumentedStore) done(op *operation, err error) {
+	defer op.span.End()
+
+	for _, expected := range []error{
+		storeerrors.ErrURLExists,
+		storeerrors.ErrShortURLTaken,
+		storeerrors.ErrAliasTaken,
+		storeerrors.ErrBatchRolledBack,
+		storeerrors.ErrAPIKeyNotFound,
+		storeerrors.ErrLoginTaken,
+		storeerrors.ErrUserNotFound,
+		storeerrors.ErrURLNotFound,
+	} {
+		if errors.Is(err, expected) {
+			op.span.SetAttributes(attribute.String("store.result", err.Error()))
+			err = nil
+			break
+		}
+	}
+	if err != nil {
+		op.span.RecordError(err)
+		op.span.SetStatus(codes.Error, err.Error())
+	}
+	s.observe(s.backend, op.name, time.Since(op.start), err)
+}
+
+func (s *instrumentedStore) Set(ctx context.Context, url models.Storage) error {
+	ctx, op := s.begin(ctx, "set")
+	err := s.store.Set(ctx, url)
+	s.done(op, err)
+	return err
+}
+
+func (s *instrumentedStore) SetAlias(ctx context.Context, url models.Storage) error {
+	ctx, op := s.begin(ctx, "set_alias")
+	err := s.store.SetAlias(ctx, url)
+	s.done(op, err)
+	return err
+}
+
+func (s *instrumentedStore) Get(ctx context.Context, shortURL string) (*models.Storage, bool) {
+	ctx, op := s.begin(ctx, "get")
+	url, ok := s.store.Get(ctx, shortURL)
+	s.done(op, nil)
+	return url, ok
+}
+
+func (s *instrumentedStore) FindShortURL(ctx context.Context, originalURL string) (string, error) {
+	ctx, op := s.begin(ctx, "find_short_url")
+	shortURL, err := s.store.FindShortURL(ctx, originalURL)
+	s.done(op, err)
+	return shortURL, err
+}
+
+func (s *instrumentedStore) SetBatch(ctx context.Context, shortURLS []models.BatchURLWrite, atomic bool) ([]models.BatchURLResult, error) {
+	ctx, op := s.begin(ctx, "set_batch")
+	results, err := s.store.SetBatch(ctx, shortURLS, atomic)
+	s.done(op, err)
+	return results, err
+}
+
+func (s *instrumentedStore) GetUserUrls(ctx context.Context, userID string) ([]models.URL, bool) {
+	ctx, op := s.begin(ctx, "get_user_urls")
+	urls, ok := s.store.GetUserUrls(ctx, userID)
+	var err error
+	if !ok {
+		err = errors.New("user URLs lookup failed")
+	}
+	s.done(op, err)
+	return urls, ok
+}
+
+func (s *instrumentedStore) ReserveSequence(ctx context.Context, name string, size uint64) (uint64, error) {
+	ctx, op := s.begin(ctx, "reserve_sequence")
+	value, err := s.store.ReserveSequence(ctx, name, size)
+	s.done(op, err)
+	return value, err
+}
+
+func (s *instrumentedStore) CountUserUrls(ctx context.Context, userID string) (int, error) {
+	ctx, op := s.begin(ctx, "count_user_urls")
+	count, err := s.store.CountUserUrls(ctx, userID)
+	s.done(op, err)
+	return count, err
+}
+
+func (s *instrumentedStore) DeleteUserUrls(ctx context.Context, userURLs []models.UserURL) error {
+	ctx, op := s.begin(ctx, "delete_user_urls")
+	err := s.store.DeleteUserUrls(ctx, userURLs)
+	s.done(op, err)
+	return err
+}
+
+func (s *instrumentedStore) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	ctx, op := s.begin(ctx, "delete_expired")
+	count, err := s.store.DeleteExpired(ctx, before)
+	s.done(op, err)
+	return count, err
+}
+
+func (s *instrumentedStore) SaveClicks(ctx context.Context, clicks []models.Click) error {
+	ctx, op := s.begin(ctx, "save_clicks")
+	err := s.store.SaveClicks(ctx, clicks)
+	s.done(op, err)
+	return err
+}
+
+func (s *instrumentedStore) UpdateOriginalURL(ctx context.Context, change models.URLChange) (*models.URLChange, error) {
+	ctx, op := s.begin(ctx, "update_original_url")
+	updated, err := s.store.UpdateOriginalURL(ctx, change)
+	s.done(op, err)
+	return updated, err
+}
+
+func (s *instrumentedStore) GetURLHistory(ctx context.Context, shortURL string) ([]models.URLChange, error) {
+	ctx, op := s.begin(ctx, "get_url_history")
+	history, err := s.store.GetURLHistory(ctx, shortURL)
+	s.done(op, err)
+	return history, err
+}
+
+func (s *instrumentedStore) EachGeneratedShortURL(ctx context.Context, fn func(shortURL string)) error {
+	ctx, op := s.begin(ctx, "each_generated_short_url")
+	err := s.store.EachGeneratedShortURL(ctx, fn)
+	s.done(op, err)
+	return err
+}
+
+func (s *instrumentedStore) GetClickStats(ctx context.Context, shortURL string) (*models.ClickStats, error) {
+	ctx, op := s.begin(ctx, "get_click_stats")
+	stats, err := s.store.GetClickStats(ctx, shortURL)
+	s.done(op, err)
+	return stats, err
+}
+
+func (s *instrumentedStore) CreateAPIKey(ctx context.Context, key models.APIKey) error {
+	ctx, op := s.begin(ctx, "create_api_key")
+	err := s.store.CreateAPIKey(ctx, key)
+	s.done(op, err)
+	return err
+}
+
+func (s *instrumentedStore) GetUserAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error) {
+	ctx, op := s.begin(ctx, "get_user_api_keys")
+	keys, err := s.store.GetUserAPIKeys(ctx, userID)
+	s.done(op, err)
+	return keys, err
+}
+
+func (s *instrumentedStore) GetAPIKey(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	ctx, op := s.begin(ctx, "get_api_key")
+	key, err := s.store.GetAPIKey(ctx, keyHash)
+	s.done(op, err)
+	return key, err
+}
+
+func (s *instrumentedStore) RevokeAPIKey(ctx context.Context, userID, keyID string) error {
+	ctx, op := s.begin(ctx, "revoke_api_key")
+	err := s.store.RevokeAPIKey(ctx, userID, keyID)
+	s.done(op, err)
+	return err
+}
+
+func (s *instrumentedStore) CreateUser(ctx context.Context, user models.User) error {
+	ctx, op := s.begin(ctx, "create_user")
+	err := s.store.CreateUser(ctx, user)
+	s.done(op, err)
+	return err
+}
+
+func (s *instrumentedStore) GetUserByLogin(ctx context.Context, login string) (*models.User, error) {
+	ctx, op := s.begin(ctx, "get_user_by_login")
+	user, err := s.store.GetUserByLogin(ctx, login)
+	s.done(op, err)
+	return user, err
+}
+
+func (s *instrumentedStore) GetUser(ctx context.Context, userID string) (*models.User, error) {
+	ctx, op := s.begin(ctx, "get_user")
+	user, err := s.store.GetUser(ctx, userID)
+	s.done(op, err)
+	return user, err
+}
+
+func (s *instrumentedStore) ClaimUserUrls(ctx context.Context, fromUserID, toUserID string) (int64, error) {
+	ctx, op := s.begin(ctx, "claim_user_urls")
+	count, err := s.store.ClaimUserUrls(ctx, fromUserID, toUserID)
+	s.done(op, err)
+	return count, err
+}
+
+func (s *instrumentedStore) ClaimUserAPIKeys(ctx context.Context, fromUserID, toUserID string) (int64, error) {
+	ctx, op := s.begin(ctx, "claim_user_api_keys")
+	count, err := s.store.ClaimUserAPIKeys(ctx, fromUserID, toUserID)
+	s.done(op, err)
+	return count, err
+}
+
+func (s *instrumentedStore) Ping() error {
+	_, op := s.begin(context.Background(), "ping")
+	err := s.store.Ping()
+	s.done(op, err)
+	return err
+}
+
+func (s *instrumentedStore) Close() error {
+	return s.store.Close()
+}
@@ -0,0 +1,83 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/learies/go-url-shortener/internal/models"
+	"github.com/learies/go-url-shortener/internal/store/storeerrors"
+)
+
+// CreateAPIKey сохраняет новый ключ доступа пользователя
+func (store *MemStore) CreateAPIKey(ctx context.Context, key models.APIKey) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.apiKeys[key.ID] = &key
+	store.apiKeyIDs[key.KeyHash] = key.ID
+	return nil
+}
+
+// GetUserAPIKeys возвращает неотозванные ключи доступа пользователя в порядке создания
+func (store *MemStore) GetUserAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	return userAPIKeys(store.apiKeys, userID), nil
+}
+
+// GetAPIKey ищет неотозванный ключ по хэшу
+func (store *MemStore) GetAPIKey(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	id, exists := store.apiKeyIDs[keyHash]
+	if !exists || store.apiKeys[id].RevokedAt != nil {
+		return nil, storeerrors.ErrAPIKeyNotFound
+	}
+	found := *store.apiKeys[id]
+	return &found, nil
+}
+
+// RevokeAPIKey отзывает ключ пользователя
+func (store *MemStore) RevokeAPIKey(ctx context.Context, userID, keyID string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	key, exists := store.apiKeys[keyID]
+	if !exists || key.UserID != userID || key.RevokedAt != nil {
+		return storeerrors.ErrAPIKeyNotFound
+	}
+	revokedAt := time.Now()
+	key.RevokedAt = &revokedAt
+	return nil
+}
+
+// ClaimUserAPIKeys передаёт ключи доступа пользователя fromUserID пользователю toUserID
+func (store *MemStore) ClaimUserAPIKeys(ctx context.Context, fromUserID, toUserID string) (int64, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	var claimed int64
+	for _, key := range store.apiKeys {
+		if key.UserID == fromUserID {
+			key.UserID = toUserID
+			claimed++
+		}
+	}
+	return claimed, nil
+}
+
+func userAPIKeys(apiKeys map[string]*models.APIKey, userID string) []models.APIKey {
+	keys := []models.APIKey{}
+	for _, key := range apiKeys {
+		if key.UserID == userID && key.RevokedAt == nil {
+			keys = append(keys, *key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys
+}
@@ -0,0 +1,41 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/learies/go-url-shortener/internal/models"
+	"github.com/learies/go-url-shortener/internal/store/storeerrors"
+)
+
+// UpdateOriginalURL меняет оригинальный URL пользователя и записывает изменение в историю.
+// Если новый оригинальный URL уже сокращён, возвращает *storeerrors.ErrConflict
+func (store *MemStore) UpdateOriginalURL(ctx context.Context, change models.URLChange) (*models.URLChange, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	url, exists := store.index.URLs[change.ShortURL]
+	if !exists || url.UserID != change.ChangedBy || url.DeletedFlag {
+		return nil, storeerrors.ErrURLNotFound
+	}
+
+	if !url.IsAlias {
+		if shortURL, exists := store.index.FindOriginal(change.NewURL); exists && shortURL != change.ShortURL {
+			return nil, &storeerrors.ErrConflict{ShortURL: shortURL}
+		}
+	}
+
+	change.OldURL = url.OriginalURL
+	updated := *url
+	updated.OriginalURL = change.NewURL
+	store.index.Put(&updated)
+	store.history[change.ShortURL] = append(store.history[change.ShortURL], change)
+	return &change, nil
+}
+
+// GetURLHistory возвращает историю изменений оригинального URL в порядке их внесения
+func (store *MemStore) GetURLHistory(ctx context.Context, shortURL string) ([]models.URLChange, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	return append([]models.URLChange{}, store.history[shortURL]...), nil
+}
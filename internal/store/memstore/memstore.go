@@ -0,0 +1,250 @@
+package memstore
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/learies/go-url-shortener/internal/analytics"
+	"github.com/learies/go-url-shortener/internal/models"
+	"github.com/learies/go-url-shortener/internal/store/storeerrors"
+	"github.com/learies/go-url-shortener/internal/store/urlindex"
+)
+
+// MemStore хранение URL в памяти процесса
+type MemStore struct {
+	index     *urlindex.Index
+	clicks    map[string]*analytics.Counter
+	history   map[string][]models.URLChange
+	apiKeys   map[string]*models.APIKey
+	apiKeyIDs map[string]string
+	users     map[string]*models.User
+	sequences map[string]uint64
+	mu        sync.RWMutex
+}
+
+// NewMemStore создаёт пустое хранилище в памяти
+func NewMemStore() *MemStore {
+	return &MemStore{
+		index:     urlindex.New(),
+		clicks:    make(map[string]*analytics.Counter),
+		history:   make(map[string][]models.URLChange),
+		apiKeys:   make(map[string]*models.APIKey),
+		apiKeyIDs: make(map[string]string),
+		users:     make(map[string]*models.User),
+		sequences: make(map[string]uint64),
+	}
+}
+
+// Set сохраняет URL в память.
+// Если оригинальный URL уже сокращён, возвращает *storeerrors.ErrConflict с сохранённым коротким URL,
+// если короткий URL уже занят другим оригинальным URL — storeerrors.ErrShortURLTaken
+func (store *MemStore) Set(ctx context.Context, url models.Storage) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if err := store.index.CheckInsert(url.ShortURL, url.OriginalURL); err != nil {
+		return err
+	}
+
+	store.setURL(url)
+	return nil
+}
+
+// FindShortURL возвращает сгенерированный короткий URL, под которым уже сокращён оригинальный URL
+func (store *MemStore) FindShortURL(ctx context.Context, originalURL string) (string, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	shortURL, _ := store.index.FindOriginal(originalURL)
+	return shortURL, nil
+}
+
+// SetAlias сохраняет URL под пользовательским алиасом.
+// Если алиас уже занят, возвращает storeerrors.ErrAliasTaken
+func (store *MemStore) SetAlias(ctx context.Context, url models.Storage) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if _, exists := store.index.URLs[url.ShortURL]; exists {
+		return storeerrors.ErrAliasTaken
+	}
+
+	url.IsAlias = true
+	store.setURL(url)
+	return nil
+}
+
+func (store *MemStore) setURL(url models.Storage) {
+	url.ID = uuid.New().String()
+	url.DeletedFlag = false
+	store.index.Put(&url)
+}
+
+// Get получает URL из памяти
+func (store *MemStore) Get(ctx context.Context, shortURL string) (*models.Storage, bool) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	url, exists := store.index.URLs[shortURL]
+	if !exists {
+		return nil, false
+	}
+
+	s := *url
+	return &s, true
+}
+
+// SetBatch сохраняет пакет URL в память
+func (store *MemStore) SetBatch(ctx context.Context, shortURLS []models.BatchURLWrite, atomic bool) ([]models.BatchURLResult, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	results := make([]models.BatchURLResult, len(shortURLS))
+	batch := store.index.NewBatch(len(shortURLS))
+	failed := false
+	for i, url := range shortURLS {
+		results[i] = models.BatchURLResult{CorrelationID: url.CorrelationID, ShortURL: url.ShortURL, Status: models.BatchStatusCreated}
+
+		if existingShortURL, exists := batch.FindConflict(url.ShortURL, url.OriginalURL); exists {
+			failed = true
+			if existingShortURL == "" {
+				results[i].Status = models.BatchStatusTaken
+			} else {
+				results[i].Status = models.BatchStatusExists
+				results[i].ShortURL = existingShortURL
+			}
+		}
+	}
+
+	if atomic && failed {
+		return results, storeerrors.ErrBatchRolledBack
+	}
+
+	for i, url := range shortURLS {
+		if results[i].Status == models.BatchStatusCreated {
+			store.setURL(models.Storage{
+				ShortURL:    url.ShortURL,
+				OriginalURL: url.OriginalURL,
+				UserID:      url.UserID,
+				ExpiresAt:   url.ExpiresAt,
+			})
+		}
+	}
+
+	return results, nil
+}
+
+// GetUserUrls получает URL, принадлежащие пользователю
+func (store *MemStore) GetUserUrls(ctx context.Context, userID string) ([]models.URL, bool) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	var userUrls []models.URL
+	for _, url := range store.index.URLs {
+		if url.UserID == userID {
+			userUrls = append(userUrls, models.URL{ShortURL: url.ShortURL, OriginalURL: url.OriginalURL})
+		}
+	}
+
+	return userUrls, true
+}
+
+// EachGeneratedShortURL вызывает fn для каждого сгенерированного короткого URL
+func (store *MemStore) EachGeneratedShortURL(ctx context.Context, fn func(shortURL string)) error {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	for shortURL, url := range store.index.URLs {
+		if !url.IsAlias {
+			fn(shortURL)
+		}
+	}
+	return nil
+}
+
+// CountUserUrls возвращает число действующих URL пользователя
+func (store *MemStore) CountUserUrls(ctx context.Context, userID string) (int, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	now := time.Now()
+	var count int
+	for _, url := range store.index.URLs {
+		if url.UserID == userID && !url.DeletedFlag && !url.Expired(now) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// DeleteUserUrls устанавливает флаг удаления для URL, принадлежащих пользователю
+func (store *MemStore) DeleteUserUrls(ctx context.Context, userURLs []models.UserURL) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	for _, userURL := range userURLs {
+		if url, exists := store.index.URLs[userURL.ShortURL]; exists && url.UserID == userURL.UserID {
+			url.DeletedFlag = true
+		}
+	}
+	return nil
+}
+
+// DeleteExpired удаляет URL, срок действия которых истёк раньше before
+func (store *MemStore) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	var deleted int64
+	for shortURL, url := range store.index.URLs {
+		if url.ExpiresAt != nil && url.ExpiresAt.Before(before) {
+			store.index.Remove(shortURL)
+			delete(store.clicks, shortURL)
+			delete(store.history, shortURL)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// SaveClicks учитывает пачку событий перехода в статистике в памяти
+func (store *MemStore) SaveClicks(ctx context.Context, clicks []models.Click) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	for _, click := range clicks {
+		counter, ok := store.clicks[click.ShortURL]
+		if !ok {
+			counter = analytics.NewCounter()
+			store.clicks[click.ShortURL] = counter
+		}
+		counter.Add(click)
+	}
+
+	return nil
+}
+
+// GetClickStats получает статистику переходов по короткому URL
+func (store *MemStore) GetClickStats(ctx context.Context, shortURL string) (*models.ClickStats, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	counter, ok := store.clicks[shortURL]
+	if !ok {
+		counter = analytics.NewCounter()
+	}
+	return counter.Stats(shortURL), nil
+}
+
+// Ping проверяет доступность хранилища URL
+func (store *MemStore) Ping() error {
+	return nil
+}
+
+// Close ничего не делает: данные хранилища живут только в памяти процесса
+func (store *MemStore) Close() error {
+	return nil
+}
@@ -0,0 +1,140 @@
+package memstore
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/learies/go-url-shortener/internal/models"
+	"github.com/learies/go-url-shortener/internal/store/storeerrors"
+)
+
+func TestMemStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemStore()
+
+	t.Run("Set and Get", func(t *testing.T) {
+		require.NoError(t, store.Set(ctx, models.Storage{ShortURL: "abc", OriginalURL: "http://example.com", UserID: "user1"}))
+
+		s, exists := store.Get(ctx, "abc")
+		require.True(t, exists)
+		assert.Equal(t, "http://example.com", s.OriginalURL)
+		assert.Equal(t, "user1", s.UserID)
+		assert.NotEmpty(t, s.ID)
+
+		_, exists = store.Get(ctx, "missing")
+		assert.False(t, exists)
+	})
+
+	t.Run("Set collision", func(t *testing.T) {
+		err := store.Set(ctx, models.Storage{ShortURL: "abc", OriginalURL: "http://example.org", UserID: "user1"})
+		assert.ErrorIs(t, err, storeerrors.ErrShortURLTaken)
+
+		// Повторное сокращение того же URL возвращает сохранённый короткий URL
+		err = store.Set(ctx, models.Storage{ShortURL: "abc", OriginalURL: "http://example.com", UserID: "user1"})
+		var conflict *storeerrors.ErrConflict
+		require.ErrorAs(t, err, &conflict)
+		assert.Equal(t, "abc", conflict.ShortURL)
+
+		err = store.Set(ctx, models.Storage{ShortURL: "xyz", OriginalURL: "http://example.com", UserID: "user2"})
+		require.ErrorAs(t, err, &conflict)
+		assert.Equal(t, "abc", conflict.ShortURL)
+	})
+
+	t.Run("SetAlias taken", func(t *testing.T) {
+		require.NoError(t, store.SetAlias(ctx, models.Storage{ShortURL: "sale", OriginalURL: "http://example.com", UserID: "user2"}))
+
+		err := store.SetAlias(ctx, models.Storage{ShortURL: "sale", OriginalURL: "http://example.com", UserID: "user1"})
+		assert.ErrorIs(t, err, storeerrors.ErrAliasTaken)
+	})
+
+	t.Run("GetUserUrls returns only own URLs", func(t *testing.T) {
+		urls, ok := store.GetUserUrls(ctx, "user2")
+		require.True(t, ok)
+		assert.Equal(t, []models.URL{{ShortURL: "sale", OriginalURL: "http://example.com"}}, urls)
+	})
+
+	t.Run("DeleteUserUrls checks ownership", func(t *testing.T) {
+		require.NoError(t, store.DeleteUserUrls(ctx, []models.UserURL{
+			{UserID: "user1", ShortURL: "sale"},
+			{UserID: "user1", ShortURL: "abc"},
+		}))
+
+		s, _ := store.Get(ctx, "sale")
+		assert.False(t, s.DeletedFlag)
+		s, _ = store.Get(ctx, "abc")
+		assert.True(t, s.DeletedFlag)
+	})
+
+	t.Run("DeleteExpired", func(t *testing.T) {
+		expiresAt := time.Now().Add(-time.Hour)
+		require.NoError(t, store.Set(ctx, models.Storage{ShortURL: "old", OriginalURL: "http://example.com/old", ExpiresAt: &expiresAt}))
+
+		deleted, err := store.DeleteExpired(ctx, time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), deleted)
+
+		_, exists := store.Get(ctx, "old")
+		assert.False(t, exists)
+	})
+
+	t.Run("re-shortens deleted and expired URLs", func(t *testing.T) {
+		// URL под кодом abc удалён выше, поэтому сокращается заново
+		require.NoError(t, store.Set(ctx, models.Storage{ShortURL: "abc2", OriginalURL: "http://example.com", UserID: "user1"}))
+		err := store.Set(ctx, models.Storage{ShortURL: "abc3", OriginalURL: "http://example.com", UserID: "user1"})
+		var conflict *storeerrors.ErrConflict
+		require.ErrorAs(t, err, &conflict)
+		assert.Equal(t, "abc2", conflict.ShortURL)
+
+		// Код удалённого URL остаётся занятым
+		err = store.Set(ctx, models.Storage{ShortURL: "abc", OriginalURL: "http://example.com/other", UserID: "user1"})
+		assert.ErrorIs(t, err, storeerrors.ErrShortURLTaken)
+
+		expiresAt := time.Now().Add(-time.Minute)
+		require.NoError(t, store.Set(ctx, models.Storage{ShortURL: "exp1", OriginalURL: "http://example.com/expired", ExpiresAt: &expiresAt}))
+		require.NoError(t, store.Set(ctx, models.Storage{ShortURL: "exp2", OriginalURL: "http://example.com/expired"}))
+
+		_, err = store.DeleteExpired(ctx, time.Now())
+		require.NoError(t, err)
+		shortURL, err := store.FindShortURL(ctx, "http://example.com/expired")
+		require.NoError(t, err)
+		assert.Equal(t, "exp2", shortURL)
+	})
+
+	t.Run("concurrent access", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				store.Get(ctx, "abc")
+				store.SaveClicks(ctx, []models.Click{{ShortURL: "abc", Timestamp: time.Now()}})
+			}()
+		}
+		wg.Wait()
+
+		stats, err := store.GetClickStats(ctx, "abc")
+		require.NoError(t, err)
+		assert.Equal(t, int64(50), stats.TotalClicks)
+	})
+}
+
+func TestCountUserUrls(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemStore()
+	past := time.Now().Add(-time.Hour)
+
+	require.NoError(t, store.Set(ctx, models.Storage{ShortURL: "a", OriginalURL: "http://example.com/a", UserID: "user"}))
+	require.NoError(t, store.Set(ctx, models.Storage{ShortURL: "b", OriginalURL: "http://example.com/b", UserID: "user"}))
+	require.NoError(t, store.Set(ctx, models.Storage{ShortURL: "c", OriginalURL: "http://example.com/c", UserID: "user", ExpiresAt: &past}))
+	require.NoError(t, store.Set(ctx, models.Storage{ShortURL: "d", OriginalURL: "http://example.com/d", UserID: "other"}))
+	require.NoError(t, store.DeleteUserUrls(ctx, []models.UserURL{{UserID: "user", ShortURL: "b"}}))
+
+	count, err := store.CountUserUrls(ctx, "user")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
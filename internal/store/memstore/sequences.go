@@ -0,0 +1,13 @@
+package memstore
+
+import "context"
+
+// ReserveSequence увеличивает счётчик name на size и возвращает его прежнее значение
+func (store *MemStore) ReserveSequence(ctx context.Context, name string, size uint64) (uint64, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	value := store.sequences[name]
+	store.sequences[name] = value + size
+	return value, nil
+}
@@ -0,0 +1,64 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/learies/go-url-shortener/internal/models"
+	"github.com/learies/go-url-shortener/internal/store/storeerrors"
+)
+
+// CreateUser регистрирует пользователя
+func (store *MemStore) CreateUser(ctx context.Context, user models.User) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	for _, existing := range store.users {
+		if existing.Login == user.Login {
+			return storeerrors.ErrLoginTaken
+		}
+	}
+	store.users[user.ID] = &user
+	return nil
+}
+
+// GetUserByLogin ищет пользователя по логину
+func (store *MemStore) GetUserByLogin(ctx context.Context, login string) (*models.User, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	for _, user := range store.users {
+		if user.Login == login {
+			found := *user
+			return &found, nil
+		}
+	}
+	return nil, storeerrors.ErrUserNotFound
+}
+
+// GetUser ищет пользователя по ID
+func (store *MemStore) GetUser(ctx context.Context, userID string) (*models.User, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	user, exists := store.users[userID]
+	if !exists {
+		return nil, storeerrors.ErrUserNotFound
+	}
+	found := *user
+	return &found, nil
+}
+
+// ClaimUserUrls передаёт URL пользователя fromUserID пользователю toUserID
+func (store *MemStore) ClaimUserUrls(ctx context.Context, fromUserID, toUserID string) (int64, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	var claimed int64
+	for _, url := range store.index.URLs {
+		if url.UserID == fromUserID {
+			url.UserID = toUserID
+			claimed++
+		}
+	}
+	return claimed, nil
+}
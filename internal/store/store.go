@@ -2,23 +2,67 @@ package store
 
 import (
 	"context"
-	"os"
+	"time"
 
 	"github.com/learies/go-url-shortener/config"
 	"github.com/learies/go-url-shortener/internal/database"
 	"github.com/learies/go-url-shortener/internal/models"
 	"github.com/learies/go-url-shortener/internal/store/dbstore"
 	"github.com/learies/go-url-shortener/internal/store/filestore"
+	"github.com/learies/go-url-shortener/internal/store/memstore"
 )
 
 // Store интерфейс для хранилища URL
 type Store interface {
-	Set(ctx context.Context, shortURL, originalURL, userID string) error
+	Set(ctx context.Context, url models.Storage) error
+	SetAlias(ctx context.Context, url models.Storage) error
 	Get(ctx context.Context, shortURL string) (*models.Storage, bool)
-	SetBatch(ctx context.Context, shortURLS []models.BatchURLWrite)
+	// FindShortURL возвращает сгенерированный короткий URL, под которым уже сокращён
+	// оригинальный URL, или пустую строку, если такого нет
+	FindShortURL(ctx context.Context, originalURL string) (string, error)
+	// SetBatch сохраняет пакет URL и возвращает результат по каждому элементу.
+	// При atomic пакет сохраняется только целиком: если хотя бы один элемент
+	// не создан, ничего не сохраняется и возвращается storeerrors.ErrBatchRolledBack
+	SetBatch(ctx context.Context, shortURLS []models.BatchURLWrite, atomic bool) ([]models.BatchURLResult, error)
 	GetUserUrls(ctx context.Context, userID string) ([]models.URL, bool)
-	DeleteUserUrls(ctx context.Context, deleteUserURLs <-chan models.UserURL)
+	// EachGeneratedShortURL вызывает fn для каждого сгенерированного (не алиаса) короткого URL
+	EachGeneratedShortURL(ctx context.Context, fn func(shortURL string)) error
+	// ReserveSequence увеличивает постоянный счётчик name на size и возвращает его
+	// прежнее значение. Новый счётчик начинается с нуля
+	ReserveSequence(ctx context.Context, name string, size uint64) (uint64, error)
+	// CountUserUrls возвращает число действующих (не удалённых и не истёкших) URL пользователя
+	CountUserUrls(ctx context.Context, userID string) (int, error)
+	// UpdateOriginalURL меняет оригинальный URL пользователя и записывает изменение в историю.
+	// OldURL в возвращаемой записи заполняется прежним значением. Если у пользователя
+	// нет такого неудалённого URL, возвращает storeerrors.ErrURLNotFound
+	UpdateOriginalURL(ctx context.Context, change models.URLChange) (*models.URLChange, error)
+	// GetURLHistory возвращает историю изменений оригинального URL в порядке их внесения
+	GetURLHistory(ctx context.Context, shortURL string) ([]models.URLChange, error)
+	DeleteUserUrls(ctx context.Context, userURLs []models.UserURL) error
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+	SaveClicks(ctx context.Context, clicks []models.Click) error
+	GetClickStats(ctx context.Context, shortURL string) (*models.ClickStats, error)
+	// CreateAPIKey сохраняет новый ключ доступа пользователя
+	CreateAPIKey(ctx context.Context, key models.APIKey) error
+	// GetUserAPIKeys возвращает неотозванные ключи доступа пользователя
+	GetUserAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error)
+	// GetAPIKey ищет неотозванный ключ по хэшу. Если ключа нет, возвращает storeerrors.ErrAPIKeyNotFound
+	GetAPIKey(ctx context.Context, keyHash string) (*models.APIKey, error)
+	// RevokeAPIKey отзывает ключ пользователя. Если ключа нет, возвращает storeerrors.ErrAPIKeyNotFound
+	RevokeAPIKey(ctx context.Context, userID, keyID string) error
+	// CreateUser регистрирует пользователя. Если логин занят, возвращает storeerrors.ErrLoginTaken
+	CreateUser(ctx context.Context, user models.User) error
+	// GetUserByLogin ищет пользователя по логину. Если его нет, возвращает storeerrors.ErrUserNotFound
+	GetUserByLogin(ctx context.Context, login string) (*models.User, error)
+	// GetUser ищет пользователя по ID. Если его нет, возвращает storeerrors.ErrUserNotFound
+	GetUser(ctx context.Context, userID string) (*models.User, error)
+	// ClaimUserUrls передаёт URL пользователя fromUserID пользователю toUserID
+	ClaimUserUrls(ctx context.Context, fromUserID, toUserID string) (int64, error)
+	// ClaimUserAPIKeys передаёт ключи доступа пользователя fromUserID пользователю toUserID
+	ClaimUserAPIKeys(ctx context.Context, fromUserID, toUserID string) (int64, error)
 	Ping() error
+	// Close сбрасывает накопленные данные и освобождает ресурсы хранилища
+	Close() error
 }
 
 // NewStore создаёт новое хранилище URL
@@ -33,21 +77,11 @@ func NewStore(cfg config.Config) (Store, error) {
 		return store, nil
 	}
 
-	// Используем файловое хранилище
-	store := &filestore.FileStore{
-		URLMapping: make(map[string]string),
-		FilePath:   cfg.FileStoragePath,
+	if cfg.FileStoragePath == "" {
+		// Без настроенного хранилища данные живут только в памяти процесса
+		return memstore.NewMemStore(), nil
 	}
 
-	if store.FilePath == "" {
-		// Создание временного файла
-		tmpFile, err := os.CreateTemp("/tmp", "urlstore-*.json")
-		if err != nil {
-			return nil, err
-		}
-		defer tmpFile.Close()
-		store.FilePath = tmpFile.Name()
-	}
-	store.LoadFromFile(store.FilePath)
-	return store, nil
+	// Используем файловое хранилище
+	return filestore.NewFileStore(cfg.FileStoragePath, cfg.FileCompactionInterval)
 }
@@ -0,0 +1,36 @@
+package storeerrors
+
+import "errors"
+
+var (
+	// ErrURLExists оригинальный URL уже сокращён
+	ErrURLExists = errors.New("original URL already exists")
+	// ErrShortURLTaken короткий URL уже занят другим оригинальным URL
+	ErrShortURLTaken = errors.New("short URL is taken by another URL")
+	// ErrAliasTaken пользовательский алиас уже занят
+	ErrAliasTaken = errors.New("alias is already taken")
+	// ErrBatchRolledBack пакет в режиме «всё или ничего» отменён из-за неуспешных элементов
+	ErrBatchRolledBack = errors.New("batch rolled back")
+	// ErrAPIKeyNotFound ключ доступа не найден или отозван
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	// ErrLoginTaken логин уже занят другим пользователем
+	ErrLoginTaken = errors.New("login is already taken")
+	// ErrUserNotFound пользователь не зарегистрирован
+	ErrUserNotFound = errors.New("user not found")
+	// ErrURLNotFound у пользователя нет такого неудалённого короткого URL
+	ErrURLNotFound = errors.New("URL not found")
+)
+
+// ErrConflict оригинальный URL уже сокращён, ShortURL содержит сохранённый короткий URL.
+// errors.Is(err, ErrURLExists) для него возвращает true
+type ErrConflict struct {
+	ShortURL string
+}
+
+func (e *ErrConflict) Error() string {
+	return ErrURLExists.Error() + ": " + e.ShortURL
+}
+
+func (e *ErrConflict) Is(target error) bool {
+	return target == ErrURLExists
+}
@@ -0,0 +1,126 @@
+// Package urlindex содержит общий для memstore и filestore индекс URL в памяти
+// и правила поиска конфликтов при вставке, повторяющие ограничения базы данных
+package urlindex
+
+import (
+	"time"
+
+	"github.com/learies/go-url-shortener/internal/models"
+	"github.com/learies/go-url-shortener/internal/store/storeerrors"
+)
+
+// Index записи по короткому URL и индекс оригинальных URL сгенерированных коротких URL,
+// как частичный уникальный индекс urls_original_url_idx в базе данных.
+// Index не защищён от одновременного доступа: хранилища вызывают его под своей блокировкой
+type Index struct {
+	// URLs записи по короткому URL. Добавлять и удалять записи нужно через Put и Remove,
+	// чтобы индекс оригинальных URL оставался согласованным
+	URLs      map[string]*models.Storage
+	originals map[string]string
+}
+
+// New создаёт пустой индекс
+func New() *Index {
+	return &Index{
+		URLs:      make(map[string]*models.Storage),
+		originals: make(map[string]string),
+	}
+}
+
+// Put сохраняет запись, заменяя прежнюю запись с тем же коротким URL
+func (idx *Index) Put(url *models.Storage) {
+	idx.unindex(url.ShortURL)
+	idx.URLs[url.ShortURL] = url
+	if !url.IsAlias {
+		idx.originals[url.OriginalURL] = url.ShortURL
+	}
+}
+
+// Remove удаляет запись короткого URL
+func (idx *Index) Remove(shortURL string) {
+	idx.unindex(shortURL)
+	delete(idx.URLs, shortURL)
+}
+
+// unindex убирает оригинальный URL записи shortURL из индекса оригинальных URL
+func (idx *Index) unindex(shortURL string) {
+	if url, exists := idx.URLs[shortURL]; exists && idx.originals[url.OriginalURL] == shortURL {
+		delete(idx.originals, url.OriginalURL)
+	}
+}
+
+// FindOriginal возвращает действующий (не удалённый и не истёкший) сгенерированный
+// короткий URL, под которым сохранён оригинальный URL
+func (idx *Index) FindOriginal(originalURL string) (string, bool) {
+	shortURL, exists := idx.originals[originalURL]
+	if !exists {
+		return "", false
+	}
+	if url := idx.URLs[shortURL]; url.DeletedFlag || url.Expired(time.Now()) {
+		return "", false
+	}
+	return shortURL, true
+}
+
+// FindConflict ищет запись, с которой конфликтует вставка URL. Возвращает короткий URL,
+// под которым оригинальный URL уже сохранён, или пустую строку, если короткий URL
+// занят другим оригинальным URL. Удалённые и истёкшие URL не мешают сократить
+// оригинальный URL заново, но их короткие URL остаются занятыми
+func (idx *Index) FindConflict(shortURL, originalURL string) (string, bool) {
+	if existingShortURL, exists := idx.FindOriginal(originalURL); exists {
+		return existingShortURL, true
+	}
+	if _, exists := idx.URLs[shortURL]; exists {
+		return "", true
+	}
+	return "", false
+}
+
+// CheckInsert проверяет, можно ли сохранить URL. Если оригинальный URL уже сокращён,
+// возвращает *storeerrors.ErrConflict с сохранённым коротким URL,
+// если короткий URL уже занят другим оригинальным URL — storeerrors.ErrShortURLTaken
+func (idx *Index) CheckInsert(shortURL, originalURL string) error {
+	existingShortURL, exists := idx.FindConflict(shortURL, originalURL)
+	switch {
+	case !exists:
+		return nil
+	case existingShortURL == "":
+		return storeerrors.ErrShortURLTaken
+	default:
+		return &storeerrors.ErrConflict{ShortURL: existingShortURL}
+	}
+}
+
+// Batch проверяет конфликты пакета URL с индексом и с ещё не сохранёнными элементами пакета
+type Batch struct {
+	idx              *Index
+	pending          map[string]string
+	pendingOriginals map[string]string
+}
+
+// NewBatch начинает проверку пакета из size URL
+func (idx *Index) NewBatch(size int) *Batch {
+	return &Batch{
+		idx:              idx,
+		pending:          make(map[string]string, size),
+		pendingOriginals: make(map[string]string, size),
+	}
+}
+
+// FindConflict ищет конфликт URL так же, как Index.FindConflict, учитывая
+// добавленные в пакет элементы. URL без конфликта добавляется в пакет
+func (b *Batch) FindConflict(shortURL, originalURL string) (string, bool) {
+	if existingShortURL, exists := b.idx.FindConflict(shortURL, originalURL); exists {
+		return existingShortURL, true
+	}
+	if existingShortURL, exists := b.pendingOriginals[originalURL]; exists {
+		return existingShortURL, true
+	}
+	if _, exists := b.pending[shortURL]; exists {
+		return "", true
+	}
+
+	b.pending[shortURL] = originalURL
+	b.pendingOriginals[originalURL] = shortURL
+	return "", false
+}
@@ -0,0 +1,61 @@
+package urlindex
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/learies/go-url-shortener/internal/models"
+	"github.com/learies/go-url-shortener/internal/store/storeerrors"
+)
+
+func TestIndex(t *testing.T) {
+	idx := New()
+	expiredAt := time.Now().Add(-time.Minute)
+	idx.Put(&models.Storage{ShortURL: "live", OriginalURL: "http://example.com/live"})
+	idx.Put(&models.Storage{ShortURL: "deleted", OriginalURL: "http://example.com/deleted", DeletedFlag: true})
+	idx.Put(&models.Storage{ShortURL: "expired", OriginalURL: "http://example.com/expired", ExpiresAt: &expiredAt})
+	idx.Put(&models.Storage{ShortURL: "alias", OriginalURL: "http://example.com/alias", IsAlias: true})
+
+	t.Run("finds conflicts with live URLs only", func(t *testing.T) {
+		var conflict *storeerrors.ErrConflict
+		assert.ErrorAs(t, idx.CheckInsert("new", "http://example.com/live"), &conflict)
+		assert.Equal(t, "live", conflict.ShortURL)
+
+		assert.NoError(t, idx.CheckInsert("new", "http://example.com/deleted"))
+		assert.NoError(t, idx.CheckInsert("new", "http://example.com/expired"))
+		assert.NoError(t, idx.CheckInsert("new", "http://example.com/alias"))
+
+		// Короткие URL удалённых и истёкших записей остаются занятыми
+		assert.ErrorIs(t, idx.CheckInsert("expired", "http://example.com/other"), storeerrors.ErrShortURLTaken)
+	})
+
+	t.Run("reindexes replaced and removed URLs", func(t *testing.T) {
+		idx.Put(&models.Storage{ShortURL: "live", OriginalURL: "http://example.com/moved"})
+		_, exists := idx.FindOriginal("http://example.com/live")
+		assert.False(t, exists)
+		shortURL, exists := idx.FindOriginal("http://example.com/moved")
+		assert.True(t, exists)
+		assert.Equal(t, "live", shortURL)
+
+		idx.Remove("live")
+		_, exists = idx.FindOriginal("http://example.com/moved")
+		assert.False(t, exists)
+		assert.NoError(t, idx.CheckInsert("live", "http://example.com/moved"))
+	})
+
+	t.Run("finds conflicts within a batch", func(t *testing.T) {
+		batch := idx.NewBatch(3)
+		_, exists := batch.FindConflict("a", "http://example.com/a")
+		assert.False(t, exists)
+
+		shortURL, exists := batch.FindConflict("b", "http://example.com/a")
+		assert.True(t, exists)
+		assert.Equal(t, "a", shortURL)
+
+		shortURL, exists = batch.FindConflict("a", "http://example.com/b")
+		assert.True(t, exists)
+		assert.Empty(t, shortURL)
+	})
+}
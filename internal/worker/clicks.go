@@ -0,0 +1,114 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/learies/go-url-shortener/internal/logger"
+	"github.com/learies/go-url-shortener/internal/models"
+)
+
+// ClickSaver хранилище, умеющее сохранять пачку событий перехода
+type ClickSaver interface {
+	SaveClicks(ctx context.Context, clicks []models.Click) error
+}
+
+// ClickRecorder буферизует события перехода и пачками записывает их в хранилище
+// по достижении batchSize событий или раз в flushInterval
+type ClickRecorder struct {
+	store         ClickSaver
+	clicks        chan models.Click
+	batchSize     int
+	flushInterval time.Duration
+
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+}
+
+// NewClickRecorder создаёт и запускает конвейер записи переходов
+func NewClickRecorder(store ClickSaver, batchSize int, flushInterval time.Duration) *ClickRecorder {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	if flushInterval <= 0 {
+		flushInterval = time.Second
+	}
+
+	cr := &ClickRecorder{
+		store:         store,
+		clicks:        make(chan models.Click, batchSize*10),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		done:          make(chan struct{}),
+	}
+	go cr.run()
+	return cr
+}
+
+// Record ставит событие перехода в очередь, не блокируя обработчик запроса.
+// Если буфер переполнен, событие отбрасывается
+func (cr *ClickRecorder) Record(click models.Click) {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	if cr.closed {
+		return
+	}
+
+	select {
+	case cr.clicks <- click:
+	default:
+		logger.Log.Warn("Click buffer is full, dropping click", "shortURL", click.ShortURL)
+	}
+}
+
+// Close прекращает приём событий и дожидается записи накопленных
+func (cr *ClickRecorder) Close() {
+	cr.mu.Lock()
+	if !cr.closed {
+		cr.closed = true
+		close(cr.clicks)
+	}
+	cr.mu.Unlock()
+	<-cr.done
+}
+
+func (cr *ClickRecorder) run() {
+	defer close(cr.done)
+
+	ticker := time.NewTicker(cr.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.Click, 0, cr.batchSize)
+	for {
+		select {
+		case click, ok := <-cr.clicks:
+			if !ok {
+				cr.flush(batch)
+				return
+			}
+			batch = append(batch, click)
+			if len(batch) >= cr.batchSize {
+				cr.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			cr.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (cr *ClickRecorder) flush(batch []models.Click) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	if err := cr.store.SaveClicks(ctx, batch); err != nil {
+		logger.Log.Error("Failed to save clicks", "error", err, "count", len(batch))
+	}
+}
@@ -0,0 +1,131 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/learies/go-url-shortener/internal/logger"
+	"github.com/learies/go-url-shortener/internal/models"
+)
+
+// ErrDeleterClosed очередь удаления закрыта и больше не принимает запросы
+var ErrDeleterClosed = errors.New("deletion queue is closed")
+
+// UserURLDeleter хранилище, умеющее пачкой помечать URL пользователей удалёнными
+type UserURLDeleter interface {
+	DeleteUserUrls(ctx context.Context, userURLs []models.UserURL) error
+}
+
+// Deleter собирает запросы на удаление URL из всех обработчиков в общую очередь
+// и пачками передаёт их хранилищу по достижении batchSize элементов или раз в flushInterval
+type Deleter struct {
+	store         UserURLDeleter
+	queue         chan models.UserURL
+	batchSize     int
+	flushInterval time.Duration
+	// batched число URL, собранных в текущую пачку, но ещё не переданных хранилищу
+	batched atomic.Int64
+
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+}
+
+// NewDeleter создаёт и запускает сервис удаления URL
+func NewDeleter(store UserURLDeleter, batchSize int, flushInterval time.Duration) *Deleter {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	if flushInterval <= 0 {
+		flushInterval = time.Second
+	}
+
+	d := &Deleter{
+		store:         store,
+		queue:         make(chan models.UserURL, batchSize*10),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		done:          make(chan struct{}),
+	}
+	go d.run()
+	return d
+}
+
+// Enqueue ставит URL в очередь на удаление. Удаление выполняется в фоне и не зависит
+// от жизни запроса; ctx ограничивает только ожидание места в очереди
+func (d *Deleter) Enqueue(ctx context.Context, userURLs ...models.UserURL) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return ErrDeleterClosed
+	}
+
+	for _, userURL := range userURLs {
+		select {
+		case d.queue <- userURL:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Len возвращает число URL, ожидающих удаления
+func (d *Deleter) Len() int {
+	return len(d.queue) + int(d.batched.Load())
+}
+
+// Close прекращает приём запросов и дожидается удаления накопленных URL
+func (d *Deleter) Close() {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.mu.Unlock()
+	<-d.done
+}
+
+func (d *Deleter) run() {
+	defer close(d.done)
+
+	ticker := time.NewTicker(d.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.UserURL, 0, d.batchSize)
+	for {
+		select {
+		case userURL, ok := <-d.queue:
+			if !ok {
+				d.flush(batch)
+				return
+			}
+			batch = append(batch, userURL)
+			d.batched.Store(int64(len(batch)))
+			if len(batch) >= d.batchSize {
+				d.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			d.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (d *Deleter) flush(batch []models.UserURL) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	if err := d.store.DeleteUserUrls(ctx, batch); err != nil {
+		logger.Log.Error("Failed to delete URLs", "error", err, "count", len(batch))
+	}
+	d.batched.Store(0)
+}
@@ -0,0 +1,84 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/learies/go-url-shortener/internal/logger"
+	"github.com/learies/go-url-shortener/internal/models"
+)
+
+type fakeDeleter struct {
+	mu      sync.Mutex
+	batches [][]models.UserURL
+}
+
+func (f *fakeDeleter) DeleteUserUrls(ctx context.Context, userURLs []models.UserURL) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.batches = append(f.batches, append([]models.UserURL(nil), userURLs...))
+	return nil
+}
+
+func (f *fakeDeleter) sizes() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var sizes []int
+	for _, batch := range f.batches {
+		sizes = append(sizes, len(batch))
+	}
+	return sizes
+}
+
+func TestDeleter(t *testing.T) {
+	require.NoError(t, logger.Initialize("error"))
+
+	userURLs := func(n int) []models.UserURL {
+		var urls []models.UserURL
+		for i := 0; i < n; i++ {
+			urls = append(urls, models.UserURL{UserID: "user", ShortURL: string(rune('a' + i))})
+		}
+		return urls
+	}
+
+	t.Run("flushes full batches", func(t *testing.T) {
+		store := &fakeDeleter{}
+		d := NewDeleter(store, 2, time.Hour)
+		defer d.Close()
+
+		require.NoError(t, d.Enqueue(context.Background(), userURLs(4)...))
+		assert.Eventually(t, func() bool {
+			return assert.ObjectsAreEqual([]int{2, 2}, store.sizes())
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("flushes on interval", func(t *testing.T) {
+		store := &fakeDeleter{}
+		d := NewDeleter(store, 100, 10*time.Millisecond)
+		defer d.Close()
+
+		require.NoError(t, d.Enqueue(context.Background(), userURLs(3)...))
+		assert.Eventually(t, func() bool {
+			return assert.ObjectsAreEqual([]int{3}, store.sizes())
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("drains queue on close", func(t *testing.T) {
+		store := &fakeDeleter{}
+		d := NewDeleter(store, 100, time.Hour)
+
+		// Контекст запроса уже отменён, но поставленные в очередь URL всё равно удаляются
+		ctx, cancel := context.WithCancel(context.Background())
+		require.NoError(t, d.Enqueue(ctx, userURLs(5)...))
+		cancel()
+
+		d.Close()
+		assert.Equal(t, []int{5}, store.sizes())
+		assert.ErrorIs(t, d.Enqueue(context.Background(), userURLs(1)...), ErrDeleterClosed)
+	})
+}
@@ -0,0 +1,40 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/learies/go-url-shortener/internal/logger"
+)
+
+// ExpiredURLDeleter хранилище, умеющее удалять URL с истёкшим сроком действия
+type ExpiredURLDeleter interface {
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+}
+
+// RunExpirySweeper периодически удаляет URL, срок действия которых истёк
+// раньше чем retention назад. Работает до отмены ctx
+func RunExpirySweeper(ctx context.Context, store ExpiredURLDeleter, interval, retention time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := store.DeleteExpired(ctx, time.Now().Add(-retention))
+			if err != nil {
+				logger.Log.Error("Failed to delete expired URLs", "error", err)
+				continue
+			}
+			if deleted > 0 {
+				logger.Log.Info("Deleted expired URLs", "count", deleted)
+			}
+		}
+	}
+}